@@ -0,0 +1,84 @@
+package thinko
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// discardWriter 丢弃输出的 ResponseWriter, 避免基准测试统计到响应记录的分配
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardWriter) WriteHeader(int) {}
+
+func passMiddleware() HandlerFunc {
+	return func(ctx *Context) {
+		ctx.Next()
+	}
+}
+
+func benchEngine() *Engine {
+	engine := New()
+	engine.Use(passMiddleware, passMiddleware)
+	api := engine.Group("/api/v1", passMiddleware)
+	api.GET("/user/list", func(ctx *Context) {})
+	api.GET("/user/:id", func(ctx *Context) {
+		_ = ctx.Param("id")
+	})
+	api.GET("/user/:id/posts/:postId", func(ctx *Context) {
+		_ = ctx.Param("postId")
+	})
+	api.GET("/files/*filepath", func(ctx *Context) {
+		_ = ctx.Param("filepath")
+	})
+	return engine
+}
+
+func benchServe(b *testing.B, engine *Engine, target string) {
+	w := &discardWriter{header: make(http.Header)}
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.ServeHTTP(w, r)
+	}
+}
+
+func BenchmarkServeHTTPStatic(b *testing.B) {
+	benchServe(b, benchEngine(), "/api/v1/user/list")
+}
+
+func BenchmarkServeHTTPParam(b *testing.B) {
+	benchServe(b, benchEngine(), "/api/v1/user/1")
+}
+
+func BenchmarkServeHTTPMultiParam(b *testing.B) {
+	benchServe(b, benchEngine(), "/api/v1/user/1/posts/2")
+}
+
+func BenchmarkServeHTTPCatchAll(b *testing.B) {
+	benchServe(b, benchEngine(), "/api/v1/files/static/js/app.js")
+}
+
+func BenchmarkServeHTTPParallel(b *testing.B) {
+	engine := benchEngine()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/user/1/posts/2", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		w := &discardWriter{header: make(http.Header)}
+		for pb.Next() {
+			engine.ServeHTTP(w, r)
+		}
+	})
+}
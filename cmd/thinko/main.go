@@ -2,17 +2,21 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/iancoleman/strcase"
 	"github.com/spf13/cobra"
 	"github.com/watsonhaw5566/thinko"
+	tkConfig "github.com/watsonhaw5566/thinko/config"
+	tkHttp "github.com/watsonhaw5566/thinko/http"
 	"go/format"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"text/tabwriter"
 )
 
 const ThinkoCli = `
@@ -56,48 +60,35 @@ const ApiTemplate = "package api\n\n" +
 const ControllerTemplate = `
 package controller
 
-func Create%s(ctx *thinko.Context) {
-	var req api.Create%sReq
-	ctx.BindStructValidate(&req)
-	err := service.%sService().Create%s(req)
-	if err != nil {
-		ctx.Fail("创建失败")
-		return
+// 通过 thinko.Handle(router, http.MethodPost, "/create", controller.Create%s) 的方式绑定路由
+// 错误通过 thinko.Error 返回, 原始错误只记录日志, 不会输出给客户端
+
+func Create%s(ctx *thinko.Context, req *api.Create%sReq) (res string, err error) {
+	if err = service.%sService().Create%s(*req); err != nil {
+		return "", &thinko.Error{StatusCode: http.StatusInternalServerError, ErrorCode: thinko.ErrorCode.EXCEPTION, Message: "创建失败", Err: err}
 	}
-	ctx.Success("ok")
+	return "ok", nil
 }
 
-func Delete%s(ctx *thinko.Context) {
-	var req api.Delete%sReq
-	ctx.BindStructValidate(&req)
-	err := service.%sService().Delete%s(req.Id)
-	if err != nil {
-		ctx.Fail("删除失败")
-		return
+func Delete%s(ctx *thinko.Context, req *api.Delete%sReq) (res string, err error) {
+	if err = service.%sService().Delete%s(req.Id); err != nil {
+		return "", &thinko.Error{StatusCode: http.StatusInternalServerError, ErrorCode: thinko.ErrorCode.EXCEPTION, Message: "删除失败", Err: err}
 	}
-	ctx.Success("ok")
+	return "ok", nil
 }
 
-func Edit%s(ctx *thinko.Context) {
-	var req api.Edit%sReq
-	ctx.BindStructValidate(&req)
-	err := service.%sService().Edit%s(req)
-	if err != nil {
-		ctx.Fail("更新失败")
-		return
+func Edit%s(ctx *thinko.Context, req *api.Edit%sReq) (res string, err error) {
+	if err = service.%sService().Edit%s(*req); err != nil {
+		return "", &thinko.Error{StatusCode: http.StatusInternalServerError, ErrorCode: thinko.ErrorCode.EXCEPTION, Message: "更新失败", Err: err}
 	}
-	ctx.Success("ok")
+	return "ok", nil
 }
 
-func %sList(ctx *thinko.Context) {
-	var req api.%sListReq
-	ctx.BindStructValidate(&req)
-	res, err := service.%sService().%sList(req)
-	if err != nil {
-		ctx.Fail("查询失败")
-		return
+func %sList(ctx *thinko.Context, req *api.%sListReq) (res api.%sListRes, err error) {
+	if res, err = service.%sService().%sList(*req); err != nil {
+		return res, &thinko.Error{StatusCode: http.StatusInternalServerError, ErrorCode: thinko.ErrorCode.EXCEPTION, Message: "查询失败", Err: err}
 	}
-	ctx.Success(res)
+	return res, nil
 }
 `
 
@@ -189,13 +180,14 @@ func main() {
 	}
 
 	// 添加子命令
-	root.AddCommand(initCmd(), createCmd(), syncCmd(), versionCmd())
+	root.AddCommand(initCmd(), createCmd(), syncCmd(), routesCmd(), versionCmd())
 
 	// 添加简写命令
 	root.PersistentFlags().BoolP("help", "h", false, "帮助文档")
 	root.PersistentFlags().BoolP("init", "i", false, "初始化一个项目")
 	root.PersistentFlags().BoolP("create", "c", false, "创建一个业务模块")
 	root.PersistentFlags().BoolP("sync", "s", false, "同步数据库表结构体")
+	root.PersistentFlags().BoolP("routes", "r", false, "查看服务路由表")
 	root.PersistentFlags().BoolP("version", "v", false, "查看版本信息")
 
 	// 自定义帮助描述
@@ -245,7 +237,7 @@ func createCmd() *cobra.Command {
 				return
 			}
 			createModule(args[0], ApiTemplate, 5, "api")
-			createModule(args[0], ControllerTemplate, 16, "app/controller")
+			createModule(args[0], ControllerTemplate, 18, "app/controller")
 			createModule(args[0], ServiceTemplate, 28, "app/service")
 			createModule(args[0], DaoTemplate, 13, "app/dao")
 		},
@@ -400,6 +392,49 @@ func sqlToGoType(sqlType string, null string) (goType string, ok bool) {
 	return goType, true
 }
 
+// routesCmd 查看服务路由表
+func routesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "routes",
+		Short: "查看服务路由表",
+		Long:  "可通过[thinko routes 服务地址]的方式查看运行中服务的路由表,服务需设置 engine.RoutesPath,不传服务地址默认读取配置文件中的地址",
+		Run: func(cmd *cobra.Command, args []string) {
+			address := "http://127.0.0.1" + tkConfig.Config.Server.Address
+			if len(args) > 0 {
+				address = strings.TrimSuffix(args[0], "/")
+			}
+			routesPath, _ := cmd.Flags().GetString("path")
+			resp, err := tkHttp.NewClient().GET(address + routesPath)
+			if err != nil {
+				color.Red("服务连接失败%v", err)
+				return
+			}
+			var res struct {
+				Code    int                `json:"code"`
+				Message string             `json:"message"`
+				Data    []thinko.RouteInfo `json:"data"`
+			}
+			if err = json.Unmarshal(resp.ReadAll(), &res); err != nil {
+				color.Red("路由表解析出错%v", err)
+				return
+			}
+			if res.Code != 200 {
+				color.Red("路由表获取失败%s", res.Message)
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "METHOD\tPATH\tHOST\tHEADERS\tNAME\tHANDLER\tMIDDLEWARES")
+			for _, route := range res.Data {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", route.Method, route.Path, route.Host, strings.Join(route.Headers, "; "),
+					route.Name, route.Handler, strings.Join(route.Middlewares, ", "))
+			}
+			w.Flush()
+		},
+	}
+	cmd.Flags().StringP("path", "p", "/debug/routes", "服务的路由表调试地址")
+	return cmd
+}
+
 // versionCmd 显示版本
 func versionCmd() *cobra.Command {
 	return &cobra.Command{
@@ -5,6 +5,7 @@ import (
 	"github.com/tidwall/gjson"
 	"gopkg.in/yaml.v3"
 	"os"
+	"time"
 )
 
 var Config *config
@@ -16,6 +17,17 @@ type server struct {
 	StaticPrefix string `yaml:"staticPrefix"`
 	StaticPath   string `yaml:"staticPath"`
 	StaticSuffix string `yaml:"staticSuffix"`
+
+	CertFile       string        `yaml:"certFile"`       // 证书文件, 与 KeyFile 同时配置时启用 HTTPS 和 HTTP/2
+	KeyFile        string        `yaml:"keyFile"`        // 私钥文件
+	H2C            bool          `yaml:"h2c"`            // 未启用 TLS 时支持明文 HTTP/2
+	ReadTimeout    time.Duration `yaml:"readTimeout"`    // 读取请求超时, 如 10s
+	WriteTimeout   time.Duration `yaml:"writeTimeout"`   // 写入响应超时
+	IdleTimeout    time.Duration `yaml:"idleTimeout"`    // 长连接空闲超时
+	MaxHeaderBytes int           `yaml:"maxHeaderBytes"` // 请求头最大字节数
+
+	ShutdownDelay   time.Duration `yaml:"shutdownDelay"`   // 收到关闭信号后标记为未就绪, 等待该时间后再关闭服务
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"` // 等待正在处理的请求完成的最长时间, 默认 5s
 }
 
 // 日志相关配置
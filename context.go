@@ -5,12 +5,14 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/watsonhaw5566/thinko/config"
 	"github.com/watsonhaw5566/thinko/log"
 	"html/template"
 	"io"
 	"io/ioutil"
+	"math"
 	"mime/multipart"
 	"net"
 	"net/http"
@@ -20,17 +22,88 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Context 上下文
 type Context struct {
-	Response http.ResponseWriter
+	Response ResponseWriter
 	Request  *http.Request
+	writer   responseWriter
 	index    int
 	handlers []HandlerFunc
+	params   Params
+	fullPath string
 	engine   *Engine
 	cache    map[string]any
 	mutex    sync.RWMutex
+	after    []HandlerFunc
+}
+
+// abortIndex 中止后的 index, 大于任何处理链的长度
+const abortIndex = math.MaxInt32 / 2
+
+// reset 重置上下文, 从对象池取出复用时调用, 避免上一个请求的数据泄露到当前请求
+func (ctx *Context) reset(w http.ResponseWriter, r *http.Request) {
+	ctx.writer.reset(w)
+	ctx.Response = &ctx.writer
+	ctx.Request = r
+	ctx.index = -1
+	ctx.handlers = nil
+	ctx.params = ctx.params[:0]
+	ctx.fullPath = ""
+	ctx.cache = nil
+	clear(ctx.after)
+	ctx.after = ctx.after[:0]
+}
+
+// copy 复制上下文交给其他 goroutine 继续执行处理链, 原上下文在请求结束后会被复用
+func (ctx *Context) copy(w http.ResponseWriter) *Context {
+	c := &Context{
+		Request:  ctx.Request,
+		index:    ctx.index,
+		handlers: ctx.handlers,
+		params:   append(Params(nil), ctx.params...),
+		fullPath: ctx.fullPath,
+		engine:   ctx.engine,
+	}
+	c.writer.reset(w)
+	c.Response = &c.writer
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+	if ctx.cache != nil {
+		c.cache = make(map[string]any, len(ctx.cache))
+		for key, value := range ctx.cache {
+			c.cache[key] = value
+		}
+	}
+	return c
+}
+
+// Deadline 请求的截止时间, 实现 context.Context
+func (ctx *Context) Deadline() (deadline time.Time, ok bool) {
+	return ctx.Request.Context().Deadline()
+}
+
+// Done 请求结束或客户端断开连接时关闭, 实现 context.Context
+func (ctx *Context) Done() <-chan struct{} {
+	return ctx.Request.Context().Done()
+}
+
+// Err 请求被取消或超时的原因, 实现 context.Context
+func (ctx *Context) Err() error {
+	return ctx.Request.Context().Err()
+}
+
+// Value 实现 context.Context, 字符串键优先读取 Set 写入的缓存, 其余从请求的上下文中读取
+// Context 会在请求结束后被复用, 需要在请求结束后使用时请改用 ctx.Request.Context()
+func (ctx *Context) Value(key any) any {
+	if name, ok := key.(string); ok {
+		if value, exists := ctx.Get(name); exists {
+			return value
+		}
+	}
+	return ctx.Request.Context().Value(key)
 }
 
 // errorCode 定义错误码
@@ -78,6 +151,28 @@ type Exception struct {
 	Error     error  `json:"error"`
 }
 
+// Error 控制器返回的错误, 按 StatusCode ErrorCode Message 通过 Fail 输出, 为 0 时使用 Fail 的默认值
+// Err 为原始错误, 只记录日志, 不会输出给客户端
+type Error struct {
+	StatusCode int
+	ErrorCode  int
+	Message    string
+	Err        error
+}
+
+// Error 实现 error 接口
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+// Unwrap 获取原始错误
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
 // Success 成功输出信息
 func (ctx *Context) Success(data interface{}, option ...SuccessOption) {
 	config := SuccessOption{
@@ -119,34 +214,56 @@ func (ctx *Context) Fail(message string, option ...FailOption) {
 	})
 }
 
-// JSON 输出JSON
+// fail 输出控制器返回的错误, 非 *Error 的错误只记录日志, 客户端收到统一的服务异常
+func (ctx *Context) fail(err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		ctx.Log().WithError(err).Error("控制器返回错误")
+		ctx.Fail("服务异常", FailOption{StatusCode: http.StatusInternalServerError, ErrorCode: ErrorCode.EXCEPTION})
+		return
+	}
+	if e.Err != nil {
+		ctx.Log().WithError(e.Err).Warn(e.Message)
+	}
+	ctx.Fail(e.Message, FailOption{StatusCode: e.StatusCode, ErrorCode: e.ErrorCode})
+}
+
+// JSON 输出JSON, 响应已写入时忽略, 避免如 Success 之后再 Fail 输出两段JSON
 func (ctx *Context) JSON(code int, data any) {
-	// 设置响应头
-	ctx.Response.Header().Set("Content-Type", "application/json; charset=utf-8")
-	// 设置状态码
-	ctx.Response.WriteHeader(code)
+	if ctx.Response.Written() {
+		ctx.Log().Warn("响应已写入, 忽略重复的JSON输出")
+		return
+	}
 	// 将数据编码为JSON
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		http.Error(ctx.Response, "服务异常解析失败", http.StatusInternalServerError)
 		return
 	}
+	// 设置响应头
+	ctx.Response.Header().Set("Content-Type", "application/json; charset=utf-8")
+	// 设置状态码
+	ctx.Response.WriteHeader(code)
 	// 写入响应体
 	ctx.Response.Write(jsonData)
 }
 
-// XML 输出XML
+// XML 输出XML, 响应已写入时忽略, 避免如 Success 之后再 Fail 输出两段XML
 func (ctx *Context) XML(code int, data any) {
-	// 设置响应头
-	ctx.Response.Header().Set("Content-Type", "application/xml; charset=utf-8")
-	// 设置状态码
-	ctx.Response.WriteHeader(code)
+	if ctx.Response.Written() {
+		ctx.Log().Warn("响应已写入, 忽略重复的XML输出")
+		return
+	}
 	// 将数据编码为XML
 	xmlData, err := xml.Marshal(data)
 	if err != nil {
 		http.Error(ctx.Response, "服务异常解析失败", http.StatusInternalServerError)
 		return
 	}
+	// 设置响应头
+	ctx.Response.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	// 设置状态码
+	ctx.Response.WriteHeader(code)
 	// 写入响应体
 	ctx.Response.Write(xmlData)
 }
@@ -170,6 +287,27 @@ func (ctx *Context) Get(key string) (value any, ok bool) {
 	return
 }
 
+// Param 获取路由参数, 如 /user/:id 中的 id
+func (ctx *Context) Param(name string) string {
+	return ctx.params.ByName(name)
+}
+
+// ParamInt 获取整数路由参数,配合 {id:int} 约束使用,参数不存在或不是整数时返回 0
+func (ctx *Context) ParamInt(name string) int {
+	value, _ := strconv.Atoi(ctx.params.ByName(name))
+	return value
+}
+
+// FullPath 获取匹配到的路由, 如 /user/:id, 未匹配到路由时为空
+func (ctx *Context) FullPath() string {
+	return ctx.fullPath
+}
+
+// Params 获取全部路由参数
+func (ctx *Context) Params() Params {
+	return ctx.params
+}
+
 // GetQuery 获取GET请求参数
 func (ctx *Context) GetQuery(key string) string {
 	return ctx.Request.URL.Query().Get(key)
@@ -383,10 +521,12 @@ func (ctx *Context) HTML(html string) {
 	ctx.Response.Write([]byte(html))
 }
 
-// View 输出页面模板
+// View 输出页面模板, 模板中可通过 {{ url "user.show" "id" 1 }} 生成命名路由地址
 func (ctx *Context) View(name string, data any, expression ...string) {
 	ctx.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
-	tmp := template.New(name)
+	tmp := template.New(name).Funcs(template.FuncMap{
+		"url": ctx.engine.URL,
+	})
 	tpl := "*.html"
 	if len(expression) > 0 {
 		tpl = expression[0]
@@ -423,6 +563,51 @@ func (ctx *Context) Next() {
 	}
 }
 
+// Abort 中止处理链, 之后调用 Next 不会再执行后续的中间件和处理函数, 已执行的中间件在 Next 之后的代码仍会执行
+func (ctx *Context) Abort() {
+	ctx.index = abortIndex
+}
+
+// AbortWithStatus 中止处理链并输出状态码
+func (ctx *Context) AbortWithStatus(code int) {
+	ctx.Abort()
+	ctx.Response.WriteHeader(code)
+}
+
+// AbortWithStatusJSON 中止处理链并输出JSON
+func (ctx *Context) AbortWithStatusJSON(code int, data any) {
+	ctx.Abort()
+	ctx.JSON(code, data)
+}
+
+// IsAborted 处理链是否已中止
+func (ctx *Context) IsAborted() bool {
+	return ctx.index >= abortIndex
+}
+
+// After 注册处理链结束后执行的函数, 在全部中间件返回且异常处理完成后按注册的相反顺序执行
+// 处理链中止或发生异常时同样会执行, 适合记录日志、统计等需要最终状态码的操作
+func (ctx *Context) After(handler HandlerFunc) {
+	ctx.after = append(ctx.after, handler)
+}
+
+// finish 执行 After 注册的函数
+func (ctx *Context) finish() {
+	for i := len(ctx.after) - 1; i >= 0; i-- {
+		ctx.after[i](ctx)
+	}
+}
+
+// RequestID 获取 RequestID 中间件设置的请求 ID
+func (ctx *Context) RequestID() string {
+	return log.RequestID(ctx.Request.Context())
+}
+
+// Log 获取带有请求上下文的日志, 使用 RequestID 中间件时自动带上请求 ID
+func (ctx *Context) Log() *logrus.Entry {
+	return log.Log().WithContext(ctx)
+}
+
 // ClientIP 获取IP
 func (ctx *Context) ClientIP() string {
 	ip := ctx.Request.Header.Get("X-Forwarded-For")
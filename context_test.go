@@ -0,0 +1,64 @@
+package thinko
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeHTTPZeroAlloc(t *testing.T) {
+	engine := benchEngine()
+	targets := []string{
+		"/api/v1/user/list",
+		"/api/v1/user/1",
+		"/api/v1/user/1/posts/2",
+		"/api/v1/files/static/js/app.js",
+	}
+	for _, target := range targets {
+		w := &discardWriter{header: make(http.Header)}
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		engine.ServeHTTP(w, r)
+		if allocs := testing.AllocsPerRun(100, func() { engine.ServeHTTP(w, r) }); allocs > 0 {
+			t.Errorf("%s 每次请求分配 %v 次内存, 期望 0 次", target, allocs)
+		}
+	}
+}
+
+// TestContextIsolation 交替执行中断的请求和正常请求, 校验复用的 Context 不会带上一个请求的数据
+func TestContextIsolation(t *testing.T) {
+	engine := New()
+	steps := 0
+	engine.Use(func() HandlerFunc {
+		return func(ctx *Context) {
+			steps++
+			if _, ok := ctx.Get("user"); ok {
+				t.Fatal("缓存数据泄露到下一个请求")
+			}
+			if len(ctx.Params()) != 1 {
+				t.Fatalf("路由参数数量为 %d, 期望 1", len(ctx.Params()))
+			}
+			ctx.Set("user", ctx.Param("id"))
+			if ctx.Param("id") == "stop" {
+				return
+			}
+			ctx.Next()
+		}
+	})
+	engine.GET("/user/:id", func(ctx *Context) {
+		steps++
+		if value, _ := ctx.Get("user"); value != ctx.Param("id") {
+			t.Fatalf("缓存数据为 %v, 期望 %s", value, ctx.Param("id"))
+		}
+	})
+	w := &discardWriter{header: make(http.Header)}
+	stop := httptest.NewRequest(http.MethodGet, "/user/stop", nil)
+	pass := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+	for i := 0; i < 100; i++ {
+		steps = 0
+		engine.ServeHTTP(w, stop)
+		engine.ServeHTTP(w, pass)
+		if steps != 3 {
+			t.Fatalf("执行了 %d 个处理函数, 期望 3 个", steps)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package thinko
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+	"hash/fnv"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeout 单次就绪检查的超时时间
+const healthCheckTimeout = 3 * time.Second
+
+// HealthCheck 健康检查结果
+type HealthCheck struct {
+	Status  string `json:"status"`          // up 或 down
+	Latency string `json:"latency"`         // 检查耗时
+	Error   string `json:"error,omitempty"` // 检查失败原因
+}
+
+// HealthReport 健康检查报告
+type HealthReport struct {
+	Status string                 `json:"status"` // 全部检查通过为 up, 否则为 down
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// RegisterCheck 注册就绪检查, 返回错误表示未就绪
+func (engine *Engine) RegisterCheck(name string, check func(ctx context.Context) error) {
+	if engine.healthChecks == nil {
+		engine.healthChecks = make(map[string]func(ctx context.Context) error)
+	}
+	engine.healthChecks[name] = check
+}
+
+// Health 注册存活和就绪检查路由
+// 存活检查只要服务能响应就返回 200, 就绪检查在服务未就绪或任一检查失败时返回 503
+// 就绪检查包含已创建的 MySQL 连接池、Redis 客户端和通过 RegisterCheck 注册的检查
+func (engine *Engine) Health() {
+	if engine.LivenessPath != "" {
+		engine.GET(engine.LivenessPath, func(ctx *Context) {
+			ctx.JSON(http.StatusOK, HealthReport{Status: "up"})
+		}).hide()
+	}
+	if engine.ReadinessPath != "" {
+		engine.GET(engine.ReadinessPath, func(ctx *Context) {
+			report := engine.CheckHealth(ctx.Request.Context())
+			if report.Status != "up" {
+				ctx.JSON(http.StatusServiceUnavailable, report)
+				return
+			}
+			ctx.JSON(http.StatusOK, report)
+		}).hide()
+	}
+}
+
+// CheckHealth 并发执行全部就绪检查并生成报告
+func (engine *Engine) CheckHealth(ctx context.Context) HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	checks := make(map[string]func(ctx context.Context) error)
+	for name, db := range mysqlPools() {
+		checks["mysql:"+name] = db.PingContext
+	}
+	rdbInstance.Range(func(key, value any) bool {
+		client := value.(*redis.Client)
+		checks["redis:"+key.(string)] = func(ctx context.Context) error {
+			return client.Ping(ctx).Err()
+		}
+		return true
+	})
+	for name, check := range engine.healthChecks {
+		checks[name] = check
+	}
+
+	report := HealthReport{Status: "up", Checks: make(map[string]HealthCheck, len(checks))}
+	if !engine.Ready() {
+		report.Status = "down"
+		report.Checks["server"] = HealthCheck{Status: "down", Latency: "0s", Error: "服务未就绪"}
+	}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			start := time.Now()
+			err := check(ctx)
+			result := HealthCheck{Status: "up", Latency: time.Since(start).String()}
+			if err != nil {
+				result.Status = "down"
+				result.Error = err.Error()
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Checks[name] = result
+			if err != nil {
+				report.Status = "down"
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+// mysqlPools 获取全部数据库连接池, 键为不含密码的名称 user@addr/db
+// 只有连接参数不同的连接池名称相同, 此时追加连接地址的哈希区分
+func mysqlPools() map[string]*sqlx.DB {
+	links := make(map[string][]string)
+	dbs := make(map[string]*sqlx.DB)
+	dbInstance.Range(func(key, value any) bool {
+		link := key.(string)
+		name := mysqlPoolName(link)
+		links[name] = append(links[name], link)
+		dbs[link] = value.(*sqlx.DB)
+		return true
+	})
+	pools := make(map[string]*sqlx.DB, len(dbs))
+	for name, list := range links {
+		for _, link := range list {
+			if len(list) > 1 {
+				pools[fmt.Sprintf("%s#%08x", name, mysqlLinkHash(link))] = dbs[link]
+			} else {
+				pools[name] = dbs[link]
+			}
+		}
+	}
+	return pools
+}
+
+// mysqlPoolName 数据库连接池名称, 去掉连接地址中的密码
+func mysqlPoolName(link string) string {
+	dsn, err := mysql.ParseDSN(link)
+	if err != nil {
+		return "unknown"
+	}
+	return dsn.User + "@" + dsn.Addr + "/" + dsn.DBName
+}
+
+// mysqlLinkHash 去掉密码后的连接地址的哈希
+func mysqlLinkHash(link string) uint32 {
+	if dsn, err := mysql.ParseDSN(link); err == nil {
+		dsn.Passwd = ""
+		link = dsn.FormatDSN()
+	}
+	h := fnv.New32a()
+	h.Write([]byte(link))
+	return h.Sum32()
+}
@@ -0,0 +1,46 @@
+package thinko
+
+import (
+	"github.com/jmoiron/sqlx"
+	"strings"
+	"testing"
+)
+
+func TestMysqlPools(t *testing.T) {
+	links := []string{
+		"root:secret@tcp(127.0.0.1:3306)/shop",
+		"readonly:secret@tcp(127.0.0.1:3306)/shop",
+		"root:secret@tcp(127.0.0.1:3306)/order?charset=utf8mb4",
+		"root:secret@tcp(127.0.0.1:3306)/order?parseTime=true",
+	}
+	for _, link := range links {
+		db, err := sqlx.Open("mysql", link)
+		if err != nil {
+			t.Fatalf("创建连接池失败: %v", err)
+		}
+		dbInstance.Store(link, db)
+	}
+	t.Cleanup(func() {
+		for _, link := range links {
+			dbInstance.Delete(link)
+		}
+	})
+
+	pools := mysqlPools()
+	if len(pools) != len(links) {
+		t.Fatalf("连接池名称应唯一, 实际为 %v", pools)
+	}
+	for _, name := range []string{"root@127.0.0.1:3306/shop", "readonly@127.0.0.1:3306/shop"} {
+		if _, ok := pools[name]; !ok {
+			t.Fatalf("缺少连接池 %s: %v", name, pools)
+		}
+	}
+	for name := range pools {
+		if strings.Contains(name, "secret") {
+			t.Fatalf("连接池名称不应包含密码: %s", name)
+		}
+		if strings.HasPrefix(name, "root@127.0.0.1:3306/order") && !strings.Contains(name, "#") {
+			t.Fatalf("同名的连接池应追加哈希区分: %s", name)
+		}
+	}
+}
@@ -2,8 +2,11 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/watsonhaw5566/thinko/log"
+	"github.com/watsonhaw5566/thinko/trace"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -20,6 +23,7 @@ type ThinkResponse struct {
 type ThinkHttpClient struct {
 	client  http.Client
 	headers map[string]string
+	ctx     context.Context
 }
 
 func NewClient(minute ...int) *ThinkHttpClient {
@@ -39,6 +43,7 @@ func NewClient(minute ...int) *ThinkHttpClient {
 			Timeout: duration,
 		},
 		headers: make(map[string]string),
+		ctx:     context.Background(),
 	}
 	client.headers["Content-Type"] = "application/json"
 	return client
@@ -51,6 +56,20 @@ func (c *ThinkHttpClient) SetHeader(header map[string]string) *ThinkHttpClient {
 	return c
 }
 
+// WithContext 返回使用该上下文的客户端副本, 上下文中的追踪信息和请求 ID 会通过请求头传递给下游服务
+// 原客户端不受影响, 可以在多个请求之间共用, cli.WithContext(ctx).GET(url)
+func (c *ThinkHttpClient) WithContext(ctx context.Context) *ThinkHttpClient {
+	headers := make(map[string]string, len(c.headers))
+	for key, value := range c.headers {
+		headers[key] = value
+	}
+	return &ThinkHttpClient{
+		client:  c.client,
+		headers: headers,
+		ctx:     ctx,
+	}
+}
+
 func (c *ThinkHttpClient) GET(url string, params ...map[string]interface{}) (*ThinkResponse, error) {
 	if len(params) > 0 {
 		url = url + "?" + c.toValues(params[0])
@@ -113,8 +132,11 @@ func (c *ThinkHttpClient) doRequest(method string, path string, params map[strin
 		contentType = multiWriter.FormDataContentType()
 		multiWriter.Close()
 	}
-	req, err := http.NewRequest(method, path, reader)
+	ctx, span := trace.Start(c.ctx, "HTTP "+method, trace.SpanKindClient)
+	defer span.End()
+	req, err := http.NewRequestWithContext(ctx, method, path, reader)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 	for key, value := range c.headers {
@@ -123,10 +145,18 @@ func (c *ThinkHttpClient) doRequest(method string, path string, params map[strin
 			req.Header.Set("Content-Type", contentType)
 		}
 	}
+	trace.Inject(ctx, req.Header)
+	if id := log.RequestID(ctx); id != "" && req.Header.Get(log.RequestIDHeader) == "" {
+		req.Header.Set(log.RequestIDHeader, id)
+	}
+	span.SetAttribute("http.method", method)
+	span.SetAttribute("http.url", req.URL.Redacted())
 	resp, err := c.client.Do(req)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
+	span.SetAttribute("http.status_code", resp.StatusCode)
 	return &ThinkResponse{resp: resp}, nil
 }
 
@@ -0,0 +1,42 @@
+package log
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader 请求 ID 请求头
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// WithRequestID 将请求 ID 保存到 ctx
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 获取 ctx 中的请求 ID, 不存在返回空字符串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDHook 通过 WithContext 输出的日志自动带上 ctx 中的请求 ID
+// log.Log().WithContext(ctx).Info("下单成功")
+type requestIDHook struct{}
+
+// Levels 对全部级别生效
+func (requestIDHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire 添加 requestId 字段
+func (requestIDHook) Fire(entry *logrus.Entry) error {
+	if id := RequestID(entry.Context); id != "" {
+		entry.Data["requestId"] = id
+	}
+	return nil
+}
@@ -32,6 +32,7 @@ func Log() *logrus.Logger {
 			log.Out = os.Stdout
 			log.Formatter = &logrus.JSONFormatter{TimestampFormat: "2006-01-02 15:04:05"}
 			log.SetLevel(logrus.DebugLevel)
+			log.AddHook(requestIDHook{})
 		}
 		return log
 	}
@@ -68,6 +69,8 @@ func logFile() *logrus.Logger {
 		lfHook := lfshook.NewHook(writeMap, &logrus.JSONFormatter{
 			TimestampFormat: "2006-01-02 15:04:05",
 		})
+		// 请求 ID 需要在写入文件之前添加
+		logToFile.AddHook(requestIDHook{})
 		logToFile.AddHook(lfHook)
 	}
 	return logToFile
@@ -0,0 +1,246 @@
+package thinko
+
+import (
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metricsBuckets 请求耗时直方图的分桶, 单位秒
+var metricsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metrics 请求监控指标
+type metrics struct {
+	mutex     sync.Mutex
+	inFlight  atomic.Int64
+	requests  map[requestKey]uint64
+	durations map[routeKey]*histogram
+}
+
+// routeKey 按请求方法和路由统计
+type routeKey struct {
+	method string
+	route  string
+}
+
+// requestKey 按请求方法、路由和状态码统计
+type requestKey struct {
+	routeKey
+	status int
+}
+
+// histogram 耗时直方图, counts 为各分桶的数量, 不累加
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// registerMetrics 注册监控指标路由
+func (engine *Engine) registerMetrics() {
+	if engine.MetricsPath == "" {
+		return
+	}
+	engine.metrics = &metrics{
+		requests:  make(map[requestKey]uint64),
+		durations: make(map[routeKey]*histogram),
+	}
+	engine.GET(engine.MetricsPath, func(ctx *Context) {
+		ctx.Response.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		ctx.Response.Write([]byte(engine.metrics.text()))
+	}).hide()
+}
+
+// observe 记录一次请求, 未匹配到路由的请求统一记为 unmatched, 避免指标数量随请求地址膨胀
+func (m *metrics) observe(method string, route string, status int, duration time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	key := routeKey{method: method, route: route}
+	seconds := duration.Seconds()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests[requestKey{routeKey: key, status: status}]++
+	h, ok := m.durations[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(metricsBuckets))}
+		m.durations[key] = h
+	}
+	for i, bucket := range metricsBuckets {
+		if seconds <= bucket {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// text 生成 Prometheus 文本格式的监控指标
+func (m *metrics) text() string {
+	var b strings.Builder
+	m.writeHTTP(&b)
+	writeMySql(&b)
+	writeRedis(&b)
+	return b.String()
+}
+
+// writeHTTP 输出请求指标
+func (m *metrics) writeHTTP(b *strings.Builder) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	writeMetricHeader(b, "thinko_http_requests_in_flight", "gauge", "Number of HTTP requests currently being served.")
+	fmt.Fprintf(b, "thinko_http_requests_in_flight %d\n", m.inFlight.Load())
+
+	requestKeys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		if requestKeys[i].routeKey != requestKeys[j].routeKey {
+			return requestKeys[i].routeKey.less(requestKeys[j].routeKey)
+		}
+		return requestKeys[i].status < requestKeys[j].status
+	})
+	writeMetricHeader(b, "thinko_http_requests_total", "counter", "Total number of HTTP requests by method, route and status code.")
+	for _, key := range requestKeys {
+		fmt.Fprintf(b, "thinko_http_requests_total{method=%s,route=%s,status=\"%d\"} %d\n",
+			labelValue(key.method), labelValue(key.route), key.status, m.requests[key])
+	}
+
+	routeKeys := make([]routeKey, 0, len(m.durations))
+	for key := range m.durations {
+		routeKeys = append(routeKeys, key)
+	}
+	sort.Slice(routeKeys, func(i, j int) bool {
+		return routeKeys[i].less(routeKeys[j])
+	})
+	writeMetricHeader(b, "thinko_http_request_duration_seconds", "histogram", "HTTP request latency in seconds by method and route.")
+	for _, key := range routeKeys {
+		h := m.durations[key]
+		labels := fmt.Sprintf("method=%s,route=%s", labelValue(key.method), labelValue(key.route))
+		var cumulative uint64
+		for i, bucket := range metricsBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(b, "thinko_http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, formatFloat(bucket), cumulative)
+		}
+		fmt.Fprintf(b, "thinko_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(b, "thinko_http_request_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		fmt.Fprintf(b, "thinko_http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+}
+
+// writeMySql 输出数据库连接池指标
+func writeMySql(b *strings.Builder) {
+	stats := make(map[string][]float64)
+	for name, db := range mysqlPools() {
+		s := db.Stats()
+		stats[labelValue(name)] = []float64{
+			float64(s.MaxOpenConnections),
+			float64(s.OpenConnections),
+			float64(s.InUse),
+			float64(s.Idle),
+			float64(s.WaitCount),
+			s.WaitDuration.Seconds(),
+			float64(s.MaxIdleClosed),
+			float64(s.MaxIdleTimeClosed),
+			float64(s.MaxLifetimeClosed),
+		}
+	}
+	if len(stats) == 0 {
+		return
+	}
+	writePoolMetrics(b, stats, []poolMetric{
+		{"thinko_mysql_max_open_connections", "gauge", "Maximum number of open connections to the database."},
+		{"thinko_mysql_open_connections", "gauge", "Number of established connections both in use and idle."},
+		{"thinko_mysql_in_use_connections", "gauge", "Number of connections currently in use."},
+		{"thinko_mysql_idle_connections", "gauge", "Number of idle connections."},
+		{"thinko_mysql_wait_count_total", "counter", "Total number of connections waited for."},
+		{"thinko_mysql_wait_duration_seconds_total", "counter", "Total time blocked waiting for a new connection."},
+		{"thinko_mysql_max_idle_closed_total", "counter", "Total number of connections closed due to SetMaxIdleConns."},
+		{"thinko_mysql_max_idle_time_closed_total", "counter", "Total number of connections closed due to SetConnMaxIdleTime."},
+		{"thinko_mysql_max_lifetime_closed_total", "counter", "Total number of connections closed due to SetConnMaxLifetime."},
+	})
+}
+
+// writeRedis 输出 Redis 连接池指标
+func writeRedis(b *strings.Builder) {
+	stats := make(map[string][]float64)
+	rdbInstance.Range(func(key, value any) bool {
+		s := value.(*redis.Client).PoolStats()
+		stats[labelValue(key.(string))] = []float64{
+			float64(s.Hits),
+			float64(s.Misses),
+			float64(s.Timeouts),
+			float64(s.TotalConns),
+			float64(s.IdleConns),
+			float64(s.StaleConns),
+		}
+		return true
+	})
+	if len(stats) == 0 {
+		return
+	}
+	writePoolMetrics(b, stats, []poolMetric{
+		{"thinko_redis_pool_hits_total", "counter", "Number of times a free connection was found in the pool."},
+		{"thinko_redis_pool_misses_total", "counter", "Number of times a free connection was not found in the pool."},
+		{"thinko_redis_pool_timeouts_total", "counter", "Number of times a wait timeout occurred."},
+		{"thinko_redis_pool_total_connections", "gauge", "Number of total connections in the pool."},
+		{"thinko_redis_pool_idle_connections", "gauge", "Number of idle connections in the pool."},
+		{"thinko_redis_pool_stale_connections_total", "counter", "Number of stale connections removed from the pool."},
+	})
+}
+
+// poolMetric 连接池指标
+type poolMetric struct {
+	name string
+	kind string
+	help string
+}
+
+// writePoolMetrics 按连接池输出指标, stats 的值与 poolMetrics 一一对应
+func writePoolMetrics(b *strings.Builder, stats map[string][]float64, poolMetrics []poolMetric) {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, metric := range poolMetrics {
+		writeMetricHeader(b, metric.name, metric.kind, metric.help)
+		for _, name := range names {
+			fmt.Fprintf(b, "%s{pool=%s} %s\n", metric.name, name, formatFloat(stats[name][i]))
+		}
+	}
+}
+
+// writeMetricHeader 输出指标的说明和类型
+func writeMetricHeader(b *strings.Builder, name string, kind string, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// labelValue 转义标签值并加上引号
+func labelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
+
+// formatFloat 格式化指标数值
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// less 排序比较
+func (k routeKey) less(other routeKey) bool {
+	if k.route != other.route {
+		return k.route < other.route
+	}
+	return k.method < other.method
+}
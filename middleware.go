@@ -1,47 +1,345 @@
 package thinko
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/sirupsen/logrus"
 	tkConfg "github.com/watsonhaw5566/thinko/config"
 	"github.com/watsonhaw5566/thinko/log"
 	tkUtil "github.com/watsonhaw5566/thinko/util"
+	"math/rand/v2"
 	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
 )
 
-// recoveryMiddleware 全局异常捕获中间件
-func recoveryMiddleware() HandlerFunc {
-	return func(ctx *Context) {
-		defer func() {
-			if err := recover(); err != nil {
-				if e, ok := err.(Exception); ok {
-					jsonStr, _ := json.Marshal(e)
-					log.Log().Error(string(jsonStr))
-					ctx.Fail(e.Message, FailOption{
-						StatusCode: e.StateCode,
-						ErrorCode:  e.ErrorCode,
-					})
-				} else {
-					fmt.Println(err)
-				}
-			}
-		}()
-		ctx.Next()
+// recovery 全局异常捕获, Exception 按其状态码输出, 其他异常记录堆栈后交给 OnPanic 处理
+// 响应已经写入时无法再输出错误信息, 只记录日志
+func (engine *Engine) recovery(ctx *Context) {
+	err := recover()
+	if err == nil {
+		return
+	}
+	var stack []byte
+	if p, ok := err.(goroutinePanic); ok {
+		err, stack = p.value, p.stack
 	}
+	if err == http.ErrAbortHandler {
+		panic(err)
+	}
+	ctx.Abort()
+	logPanic(ctx, err, stack)
+	if ctx.Response.Written() {
+		return
+	}
+	if e, ok := err.(Exception); ok {
+		ctx.Fail(e.Message, FailOption{
+			StatusCode: e.StateCode,
+			ErrorCode:  e.ErrorCode,
+		})
+		return
+	}
+	engine.panicHandler(ctx, err)
+}
+
+// logPanic 记录异常, Exception 只记录内容, 其他异常同时记录堆栈, stack 为空时使用当前堆栈
+func logPanic(ctx *Context, err any, stack []byte) {
+	if e, ok := err.(Exception); ok {
+		jsonStr, _ := json.Marshal(e)
+		ctx.Log().Error(string(jsonStr))
+		return
+	}
+	if stack == nil {
+		stack = debug.Stack()
+	}
+	ctx.Log().WithField("stack", string(stack)).Error(fmt.Sprintf("panic: %v", err))
+}
+
+// goroutinePanic 在其他 goroutine 中捕获的异常, 带上发生异常时的堆栈, 交给 recovery 处理
+type goroutinePanic struct {
+	value any
+	stack []byte
+}
+
+// defaultPanicHandler 默认异常处理, 输出 500
+func defaultPanicHandler(ctx *Context, recovered any) {
+	ctx.Fail("服务异常", FailOption{
+		StatusCode: http.StatusInternalServerError,
+		ErrorCode:  ErrorCode.EXCEPTION,
+	})
+}
+
+// defaultNoRoute 默认路由不存在处理, 输出 404
+func defaultNoRoute(ctx *Context) {
+	ctx.Fail("路由不存在", FailOption{
+		StatusCode: http.StatusNotFound,
+		ErrorCode:  http.StatusNotFound,
+	})
+}
+
+// defaultNoMethod 默认请求方法不允许处理, 输出 405
+func defaultNoMethod(ctx *Context) {
+	ctx.Fail("请求方法不允许", FailOption{
+		StatusCode: http.StatusMethodNotAllowed,
+		ErrorCode:  http.StatusMethodNotAllowed,
+	})
 }
 
-// fileServerMiddleware 静态资源服务中间件
+// fileServerMiddleware 静态资源服务中间件, 生成处理链时按配置创建一次, 请求时只比较后缀
 func fileServerMiddleware() HandlerFunc {
+	conf := tkConfg.Config.Server
+	var suffixes []string
+	for _, suffix := range strings.Split(conf.StaticSuffix, ",") {
+		if suffix != "" {
+			suffixes = append(suffixes, "."+suffix)
+		}
+	}
+	staticPrefix := conf.StaticPrefix
+	if staticPrefix != "/" {
+		staticPrefix = "/" + staticPrefix + "/"
+	}
+	fileServer := http.StripPrefix(staticPrefix, http.FileServer(http.Dir(conf.StaticPath)))
 	return func(ctx *Context) {
 		// 如果是静态资源路径，使用文件服务器处理请求
-		if tkUtil.HasSuffix(ctx.Request.RequestURI) {
-			staticPrefix := tkConfg.Config.Server.StaticPrefix
-			if staticPrefix != "/" {
-				staticPrefix = "/" + staticPrefix + "/"
+		for _, suffix := range suffixes {
+			if strings.HasSuffix(ctx.Request.RequestURI, suffix) {
+				fileServer.ServeHTTP(ctx.Response, ctx.Request)
+				return
 			}
-			http.StripPrefix(staticPrefix, http.FileServer(http.Dir(tkConfg.Config.Server.StaticPath))).ServeHTTP(ctx.Response, ctx.Request)
-			return
 		}
 		ctx.Next()
 	}
 }
+
+// Timeout 超时中间件, 处理超过 d 时返回 503, 可通过 option 修改状态码和错误码, 如返回 504
+// 后续的中间件和处理函数在新的 goroutine 中执行, 其响应先写入缓冲区, 按时完成才输出
+// 超时后处理函数写入的响应会被丢弃, 处理函数应通过 ctx.Done() 感知超时并尽快结束
+// 处理函数的异常带上原始堆栈交给全局异常捕获处理, 超时后才发生的异常只记录日志
+// engine.GET("/report", handler, thinko.Timeout(3*time.Second))
+func Timeout(d time.Duration, option ...FailOption) MiddlewareFunc {
+	failOption := FailOption{
+		StatusCode: http.StatusServiceUnavailable,
+		ErrorCode:  ErrorCode.EXCEPTION,
+	}
+	if len(option) > 0 {
+		if option[0].StatusCode != 0 {
+			failOption.StatusCode = option[0].StatusCode
+		}
+		if option[0].ErrorCode != 0 {
+			failOption.ErrorCode = option[0].ErrorCode
+		}
+	}
+	return func() HandlerFunc {
+		return func(ctx *Context) {
+			timeoutCtx, cancel := context.WithTimeout(ctx.Request.Context(), d)
+			defer cancel()
+			ctx.Request = ctx.Request.WithContext(timeoutCtx)
+
+			writer := &timeoutWriter{header: make(http.Header)}
+			c := ctx.copy(writer)
+			done := make(chan struct{})
+			panicChan := make(chan goroutinePanic, 1)
+			go func() {
+				defer func() {
+					if err := recover(); err != nil {
+						p, ok := err.(goroutinePanic)
+						if !ok {
+							p = goroutinePanic{value: err, stack: debug.Stack()}
+						}
+						writer.mutex.Lock()
+						defer writer.mutex.Unlock()
+						// 已超时的请求不会再处理异常, 直接记录日志
+						if writer.timedOut {
+							logPanic(c, p.value, p.stack)
+							return
+						}
+						panicChan <- p
+					}
+				}()
+				c.Next()
+				close(done)
+			}()
+			// 后续处理链已交给 goroutine 执行
+			ctx.index = len(ctx.handlers)
+
+			select {
+			case err := <-panicChan:
+				panic(err)
+			case <-done:
+				writer.mutex.Lock()
+				defer writer.mutex.Unlock()
+				header := ctx.Response.Header()
+				for key, values := range writer.header {
+					header[key] = values
+				}
+				if writer.status != 0 {
+					ctx.Response.WriteHeader(writer.status)
+				}
+				ctx.Response.Write(writer.body.Bytes())
+				ctx.cache = c.cache
+				ctx.after = append(ctx.after, c.after...)
+				if c.IsAborted() {
+					ctx.Abort()
+				}
+			case <-timeoutCtx.Done():
+				writer.mutex.Lock()
+				writer.timedOut = true
+				writer.mutex.Unlock()
+				// 超时的同时发生的异常
+				select {
+				case p := <-panicChan:
+					logPanic(ctx, p.value, p.stack)
+				default:
+				}
+				ctx.Abort()
+				ctx.Fail("请求超时", failOption)
+			}
+		}
+	}
+}
+
+// AccessLogOption 访问日志配置
+type AccessLogOption struct {
+	SkipPaths     []string      // 不记录的请求路径, 如健康检查和监控指标
+	SampleRate    float64       // 正常请求的采样比例, 取值 0 到 1, 为 0 时全部记录, 异常和慢请求始终记录
+	SlowThreshold time.Duration // 慢请求阈值, 超过时以 Warn 级别记录并标记 slow, 为 0 时不区分
+}
+
+// AccessLog 访问日志中间件, 每个请求通过 log.Log() 输出一条结构化日志, 使用 RequestID 中间件时带上请求 ID
+// 日志在处理链结束后输出, 中止或发生异常的请求同样会记录最终状态码, 5xx 以 Error 级别记录
+// engine.Use(thinko.AccessLog(thinko.AccessLogOption{SkipPaths: []string{"/healthz"}, SlowThreshold: time.Second}))
+func AccessLog(option ...AccessLogOption) MiddlewareFunc {
+	config := AccessLogOption{}
+	if len(option) > 0 {
+		config = option[0]
+	}
+	skipPaths := make(map[string]struct{}, len(config.SkipPaths))
+	for _, path := range config.SkipPaths {
+		skipPaths[path] = struct{}{}
+	}
+	return func() HandlerFunc {
+		return func(ctx *Context) {
+			if _, ok := skipPaths[ctx.Request.URL.Path]; ok {
+				ctx.Next()
+				return
+			}
+			start := time.Now()
+			ctx.After(func(ctx *Context) {
+				latency := time.Since(start)
+				status := ctx.Response.Status()
+				slow := config.SlowThreshold > 0 && latency >= config.SlowThreshold
+				if status < http.StatusInternalServerError && !slow &&
+					config.SampleRate > 0 && config.SampleRate < 1 && rand.Float64() >= config.SampleRate {
+					return
+				}
+				entry := ctx.Log().WithFields(logrus.Fields{
+					"method":  ctx.Request.Method,
+					"route":   ctx.FullPath(),
+					"path":    ctx.Request.URL.Path,
+					"status":  status,
+					"latency": latency.String(),
+					"bytes":   ctx.Response.Size(),
+					"ip":      ctx.ClientIP(),
+				})
+				switch {
+				case status >= http.StatusInternalServerError:
+					entry.Error("access")
+				case slow:
+					entry.WithField("slow", true).Warn("access")
+				default:
+					entry.Info("access")
+				}
+			})
+			ctx.Next()
+		}
+	}
+}
+
+// RequestIDOption 请求 ID 配置
+type RequestIDOption struct {
+	Header    string        // 请求头名称, 默认为 X-Request-ID
+	Generator func() string // ID 生成函数, 默认为 UUID v7, 可使用 tkUtil.NewSnowflake(node).NextString
+}
+
+// RequestID 请求 ID 中间件, 优先使用上游传入的请求 ID, 没有或格式不合法时生成新的 ID
+// 请求 ID 保存在 ctx 中并写入响应头, 通过 ctx.Log() 输出的日志和 ThinkHttpClient.WithContext(ctx) 发出的请求会自动带上
+// engine.Use(thinko.RequestID())
+func RequestID(option ...RequestIDOption) MiddlewareFunc {
+	config := RequestIDOption{
+		Header:    log.RequestIDHeader,
+		Generator: tkUtil.UUIDv7,
+	}
+	if len(option) > 0 {
+		if option[0].Header != "" {
+			config.Header = option[0].Header
+		}
+		if option[0].Generator != nil {
+			config.Generator = option[0].Generator
+		}
+	}
+	return func() HandlerFunc {
+		return func(ctx *Context) {
+			id := ctx.Request.Header.Get(config.Header)
+			if !validRequestID(id) {
+				id = config.Generator()
+			}
+			ctx.Request = ctx.Request.WithContext(log.WithRequestID(ctx.Request.Context(), id))
+			ctx.Response.Header().Set(config.Header, id)
+			ctx.Next()
+		}
+	}
+}
+
+// validRequestID 上游传入的请求 ID 只允许 128 位以内的可见 ASCII 字符, 避免伪造日志内容
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// timeoutWriter 超时中间件的响应缓冲区, 超时后拒绝写入
+type timeoutWriter struct {
+	mutex    sync.Mutex
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	timedOut bool
+}
+
+// Header 响应头
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader 记录状态码, 只有第一次有效
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.timedOut || w.status != 0 {
+		return
+	}
+	w.status = code
+}
+
+// Write 写入缓冲区, 超时后返回 http.ErrHandlerTimeout
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
@@ -0,0 +1,208 @@
+package thinko
+
+import (
+	"bytes"
+	"encoding/json"
+	tkHttp "github.com/watsonhaw5566/thinko/http"
+	"github.com/watsonhaw5566/thinko/log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer 并发安全的日志缓冲区
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+// captureLog 将日志输出到缓冲区, 测试结束后恢复
+func captureLog(t *testing.T) *syncBuffer {
+	t.Helper()
+	logger := log.Log()
+	out := logger.Out
+	buf := &syncBuffer{}
+	logger.SetOutput(buf)
+	t.Cleanup(func() {
+		logger.SetOutput(out)
+	})
+	return buf
+}
+
+// failBody 解析 Fail 输出的内容
+func failBody(t *testing.T, w *httptest.ResponseRecorder) SuccessOption {
+	t.Helper()
+	var body SuccessOption
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("响应不是合法的 JSON: %q", w.Body.String())
+	}
+	return body
+}
+
+func TestTimeout(t *testing.T) {
+	t.Run("按时完成时输出处理函数的响应", func(t *testing.T) {
+		engine := New()
+		engine.GET("/fast", func(ctx *Context) {
+			ctx.Response.Header().Set("X-Handler", "fast")
+			ctx.Response.WriteHeader(http.StatusCreated)
+			ctx.Response.Write([]byte("done"))
+		}, Timeout(time.Second))
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+		if w.Code != http.StatusCreated || w.Body.String() != "done" || w.Header().Get("X-Handler") != "fast" {
+			t.Fatalf("响应为 %d %q %v", w.Code, w.Body.String(), w.Header())
+		}
+	})
+
+	t.Run("超时后丢弃处理函数的响应", func(t *testing.T) {
+		finished := make(chan struct{})
+		engine := New()
+		engine.GET("/slow", func(ctx *Context) {
+			defer close(finished)
+			<-ctx.Request.Context().Done()
+			ctx.Response.Header().Set("X-Handler", "slow")
+			ctx.Response.WriteHeader(http.StatusOK)
+			ctx.Response.Write([]byte("late"))
+		}, Timeout(10*time.Millisecond))
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		<-finished
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("状态码为 %d, 期望 503", w.Code)
+		}
+		if body := failBody(t, w); body.Code != ErrorCode.EXCEPTION || body.Message != "请求超时" {
+			t.Fatalf("响应为 %+v", body)
+		}
+		if w.Header().Get("X-Handler") != "" {
+			t.Fatal("超时后处理函数设置的响应头不应输出")
+		}
+	})
+
+	t.Run("自定义超时状态码", func(t *testing.T) {
+		engine := New()
+		engine.GET("/slow", func(ctx *Context) {
+			<-ctx.Request.Context().Done()
+		}, Timeout(10*time.Millisecond, FailOption{StatusCode: http.StatusGatewayTimeout}))
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		if w.Code != http.StatusGatewayTimeout {
+			t.Fatalf("状态码为 %d, 期望 504", w.Code)
+		}
+	})
+
+	t.Run("超时前的异常交给全局异常处理", func(t *testing.T) {
+		logs := captureLog(t)
+		engine := New()
+		engine.OnPanic(func(ctx *Context, recovered any) {
+			ctx.Fail("异常: "+recovered.(string), FailOption{StatusCode: http.StatusInternalServerError})
+		})
+		engine.GET("/panic", func(ctx *Context) {
+			panic("boom")
+		}, Timeout(time.Second))
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("状态码为 %d, 期望 500", w.Code)
+		}
+		if body := failBody(t, w); body.Message != "异常: boom" {
+			t.Fatalf("响应为 %+v", body)
+		}
+		if !strings.Contains(logs.String(), "middleware_test.go") {
+			t.Fatalf("日志中的堆栈应指向处理函数: %s", logs.String())
+		}
+	})
+
+	t.Run("超时后的异常记录日志", func(t *testing.T) {
+		logs := captureLog(t)
+		finished := make(chan struct{})
+		engine := New()
+		engine.GET("/panic", func(ctx *Context) {
+			defer close(finished)
+			<-ctx.Request.Context().Done()
+			panic("late")
+		}, Timeout(10*time.Millisecond))
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("状态码为 %d, 期望 503", w.Code)
+		}
+		<-finished
+		deadline := time.Now().Add(time.Second)
+		for !strings.Contains(logs.String(), "panic: late") {
+			if time.Now().After(deadline) {
+				t.Fatalf("超时后的异常没有记录日志: %s", logs.String())
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if !strings.Contains(logs.String(), "middleware_test.go") {
+			t.Fatalf("日志中的堆栈应指向处理函数: %s", logs.String())
+		}
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	var downstream string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = r.Header.Get(log.RequestIDHeader)
+	}))
+	defer server.Close()
+
+	engine := New()
+	engine.Use(RequestID(RequestIDOption{Generator: func() string { return "generated" }}))
+	engine.GET("/user", func(ctx *Context) {
+		if _, err := tkHttp.NewClient().WithContext(ctx).GET(server.URL); err != nil {
+			t.Errorf("请求下游服务失败: %v", err)
+		}
+		ctx.Response.Write([]byte(ctx.RequestID()))
+	})
+
+	cases := []struct {
+		name     string
+		incoming string
+		want     string
+	}{
+		{name: "使用上游传入的请求 ID", incoming: "upstream-id", want: "upstream-id"},
+		{name: "没有请求 ID 时生成", want: "generated"},
+		{name: "请求 ID 不合法时重新生成", incoming: "bad id", want: "generated"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			downstream = ""
+			r := httptest.NewRequest(http.MethodGet, "/user", nil)
+			if c.incoming != "" {
+				r.Header.Set(log.RequestIDHeader, c.incoming)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			if got := w.Header().Get(log.RequestIDHeader); got != c.want {
+				t.Fatalf("响应头中的请求 ID 为 %q, 期望 %q", got, c.want)
+			}
+			if w.Body.String() != c.want {
+				t.Fatalf("ctx.RequestID() 为 %q, 期望 %q", w.Body.String(), c.want)
+			}
+			if downstream != c.want {
+				t.Fatalf("下游收到的请求 ID 为 %q, 期望 %q", downstream, c.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,14 @@
 package thinko
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/fatih/color"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	tkConfig "github.com/watsonhaw5566/thinko/config"
+	"github.com/watsonhaw5566/thinko/trace"
 	tkUtil "github.com/watsonhaw5566/thinko/util"
 	"net/http"
 	"reflect"
@@ -98,11 +100,13 @@ type DB struct {
 	values    []interface{}
 	tx        *sqlx.Tx
 	config    Source
+	ctx       context.Context
 }
 
 type Begin struct {
 	tx     *sqlx.Tx
 	source []Source
+	ctx    context.Context
 }
 
 // 创建连接池
@@ -154,8 +158,13 @@ func createInstance(source ...Source) (instance *sqlx.DB, config Source) {
 
 // BeginTransaction 开启事务,如果不传数据源默认走的是配置文件里默认的,传了可以指定任意的数据源
 func BeginTransaction(source ...Source) *Begin {
+	return BeginTransactionContext(context.Background(), source...)
+}
+
+// BeginTransactionContext 开启事务, 上下文取消或超时时事务自动回滚, 事务中的操作都使用该上下文
+func BeginTransactionContext(ctx context.Context, source ...Source) *Begin {
 	instance, _ := createInstance(source...)
-	tx, err := instance.Beginx()
+	tx, err := instance.BeginTxx(ctx, nil)
 	if err != nil {
 		panic(Exception{
 			StateCode: http.StatusInternalServerError,
@@ -167,6 +176,7 @@ func BeginTransaction(source ...Source) *Begin {
 	return &Begin{
 		tx:     tx,
 		source: source,
+		ctx:    ctx,
 	}
 }
 
@@ -174,6 +184,7 @@ func BeginTransaction(source ...Source) *Begin {
 func (b *Begin) Db(tableName string) *DB {
 	db := Db(tableName, b.source...)
 	db.tx = b.tx
+	db.ctx = b.ctx
 	return db
 }
 
@@ -196,9 +207,27 @@ func Db(tableName string, source ...Source) (db *DB) {
 		whereStr:  "",
 		fieldStr:  "*",
 		config:    config,
+		ctx:       context.Background(),
 	}
 }
 
+// WithContext 设置上下文, 上下文取消或超时时中断 SQL 执行, 同时用于关联请求的追踪信息
+// 可以直接传入 *thinko.Context, think.Db("user").WithContext(ctx).Select(&user)
+func (db *DB) WithContext(ctx context.Context) *DB {
+	db.ctx = ctx
+	return db
+}
+
+// startSpan 开始追踪本次数据库操作, 未开启追踪时返回 nil
+func (db *DB) startSpan(sql string) *trace.Span {
+	operation, _, _ := strings.Cut(sql, " ")
+	_, span := trace.Start(db.ctx, operation+" "+db.tableName, trace.SpanKindClient)
+	span.SetAttribute("db.system", "mysql")
+	span.SetAttribute("db.sql.table", db.tableName)
+	span.SetAttribute("db.statement", sql)
+	return span
+}
+
 // Field 指定查询的字段,默认不去重
 func (db *DB) Field(fields string, distinct ...bool) *DB {
 	dis := ""
@@ -426,15 +455,20 @@ func (db *DB) InsertAll(data []interface{}, option ...InsertAllOption) (err erro
 	sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", db.tableName, intoStr, valuesStr)
 
 	var stmt *sqlx.Stmt
+	span := db.startSpan(sql)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
 	if db.tx != nil {
-		stmt, err = db.tx.Preparex(sql)
+		stmt, err = db.tx.PreparexContext(db.ctx, sql)
 	} else {
-		stmt, err = db.instance.Preparex(sql)
+		stmt, err = db.instance.PreparexContext(db.ctx, sql)
 	}
 	if err != nil {
 		return
 	}
-	_, err = stmt.Exec(db.values...)
+	_, err = stmt.ExecContext(db.ctx, db.values...)
 	if err != nil {
 		return
 	}
@@ -517,15 +551,20 @@ func (db *DB) Insert(data interface{}, option ...InsertOption) (insertId int64,
 	sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", db.tableName, intoStr, valueStr)
 
 	var stmt *sqlx.Stmt
+	span := db.startSpan(sql)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
 	if db.tx != nil {
-		stmt, err = db.tx.Preparex(sql)
+		stmt, err = db.tx.PreparexContext(db.ctx, sql)
 	} else {
-		stmt, err = db.instance.Preparex(sql)
+		stmt, err = db.instance.PreparexContext(db.ctx, sql)
 	}
 	if err != nil {
 		return
 	}
-	res, err := stmt.Exec(db.values...)
+	res, err := stmt.ExecContext(db.ctx, db.values...)
 	if err != nil {
 		return res.LastInsertId()
 	}
@@ -601,16 +640,21 @@ func (db *DB) Update(data any, option ...UpdateOption) (err error) {
 	sql := fmt.Sprintf("UPDATE %s SET %s %s", db.tableName, setStr, db.whereStr)
 
 	var stmt *sqlx.Stmt
+	span := db.startSpan(sql)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
 	if db.tx != nil {
-		stmt, err = db.tx.Preparex(sql)
+		stmt, err = db.tx.PreparexContext(db.ctx, sql)
 	} else {
-		stmt, err = db.instance.Preparex(sql)
+		stmt, err = db.instance.PreparexContext(db.ctx, sql)
 	}
 	if err != nil {
 		return
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(db.values...)
+	_, err = stmt.ExecContext(db.ctx, db.values...)
 	if err != nil {
 		return
 	}
@@ -662,16 +706,21 @@ func (db *DB) Decr(field string, num int, option ...DecrOption) (err error) {
 	}
 
 	var stmt *sqlx.Stmt
+	span := db.startSpan(sql)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
 	if db.tx != nil {
-		stmt, err = db.tx.Preparex(sql)
+		stmt, err = db.tx.PreparexContext(db.ctx, sql)
 	} else {
-		stmt, err = db.instance.Preparex(sql)
+		stmt, err = db.instance.PreparexContext(db.ctx, sql)
 	}
 	if err != nil {
 		return
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(db.values...)
+	_, err = stmt.ExecContext(db.ctx, db.values...)
 	if err != nil {
 		return
 	}
@@ -722,16 +771,21 @@ func (db *DB) Incr(field string, num int, option ...IncrOption) (err error) {
 	}
 
 	var stmt *sqlx.Stmt
+	span := db.startSpan(sql)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
 	if db.tx != nil {
-		stmt, err = db.tx.Preparex(sql)
+		stmt, err = db.tx.PreparexContext(db.ctx, sql)
 	} else {
-		stmt, err = db.instance.Preparex(sql)
+		stmt, err = db.instance.PreparexContext(db.ctx, sql)
 	}
 	if err != nil {
 		return
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(db.values...)
+	_, err = stmt.ExecContext(db.ctx, db.values...)
 	if err != nil {
 		return
 	}
@@ -784,16 +838,21 @@ func (db *DB) Delete(option ...DeleteOption) (err error) {
 		}
 	}
 
+	span := db.startSpan(sql)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
 	if db.tx != nil {
-		stmt, err = db.tx.Preparex(sql)
+		stmt, err = db.tx.PreparexContext(db.ctx, sql)
 	} else {
-		stmt, err = db.instance.Preparex(sql)
+		stmt, err = db.instance.PreparexContext(db.ctx, sql)
 	}
 	if err != nil {
 		return
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(db.values...)
+	_, err = stmt.ExecContext(db.ctx, db.values...)
 	if err != nil {
 		return
 	}
@@ -835,17 +894,22 @@ func (db *DB) Count(option ...CountOption) (count int, err error) {
 	}
 	sql := fmt.Sprintf("SELECT COUNT(*) FROM %s %s %s %s", db.tableName, db.joinStr, db.whereStr, db.lockStr)
 	var stmt *sqlx.Stmt
+	span := db.startSpan(sql)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
 	if db.tx != nil {
-		stmt, err = db.tx.Preparex(sql)
+		stmt, err = db.tx.PreparexContext(db.ctx, sql)
 	} else {
-		stmt, err = db.instance.Preparex(sql)
+		stmt, err = db.instance.PreparexContext(db.ctx, sql)
 	}
 	if err != nil {
 		return
 	}
 	defer stmt.Close()
 	udb := stmt.Unsafe()
-	err = udb.Get(&count, db.values...)
+	err = udb.GetContext(db.ctx, &count, db.values...)
 	if err != nil {
 		return
 	}
@@ -877,10 +941,15 @@ func (db *DB) FindOne(scan any, option ...FindOneOption) (err error) {
 	}
 	sql := fmt.Sprintf("SELECT %s FROM %s %s %s %s", db.fieldStr, db.tableName, db.joinStr, db.whereStr, db.lockStr)
 	var stmt *sqlx.Stmt
+	span := db.startSpan(sql)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
 	if db.tx != nil {
-		stmt, err = db.tx.Preparex(sql)
+		stmt, err = db.tx.PreparexContext(db.ctx, sql)
 	} else {
-		stmt, err = db.instance.Preparex(sql)
+		stmt, err = db.instance.PreparexContext(db.ctx, sql)
 	}
 	if err != nil {
 		return err
@@ -897,7 +966,7 @@ func (db *DB) FindOne(scan any, option ...FindOneOption) (err error) {
 	}
 
 	udb := stmt.Unsafe()
-	err = udb.Get(scan, db.values...)
+	err = udb.GetContext(db.ctx, scan, db.values...)
 	if err != nil {
 		return err
 	}
@@ -929,10 +998,15 @@ func (db *DB) Select(scan any, option ...SelectOption) (err error) {
 	}
 	sql := fmt.Sprintf("SELECT %s FROM %s %s %s %s", db.fieldStr, db.tableName, db.joinStr, db.whereStr, db.lockStr)
 	var stmt *sqlx.Stmt
+	span := db.startSpan(sql)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
 	if db.tx != nil {
-		stmt, err = db.tx.Preparex(sql)
+		stmt, err = db.tx.PreparexContext(db.ctx, sql)
 	} else {
-		stmt, err = db.instance.Preparex(sql)
+		stmt, err = db.instance.PreparexContext(db.ctx, sql)
 	}
 	if err != nil {
 		return err
@@ -949,7 +1023,7 @@ func (db *DB) Select(scan any, option ...SelectOption) (err error) {
 	}
 
 	udb := stmt.Unsafe()
-	err = udb.Select(scan, db.values...)
+	err = udb.SelectContext(db.ctx, scan, db.values...)
 	if err != nil {
 		return err
 	}
@@ -966,3 +1040,16 @@ func ExecSql(source ...Source) *sqlx.DB {
 	instance, _ := createInstance(source...)
 	return instance
 }
+
+// CloseDb 关闭全部数据库连接池, 一般在服务关闭钩子中调用
+func CloseDb() error {
+	var errs []error
+	dbInstance.Range(func(key, value any) bool {
+		if err := value.(*sqlx.DB).Close(); err != nil {
+			errs = append(errs, err)
+		}
+		dbInstance.Delete(key)
+		return true
+	})
+	return errors.Join(errs...)
+}
@@ -0,0 +1,604 @@
+package thinko
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	tkUtil "github.com/watsonhaw5566/thinko/util"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIInfo 接口文档基本信息
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// openAPIDocument OpenAPI 3.1 文档
+type openAPIDocument struct {
+	OpenAPI string                                  `json:"openapi"`
+	Info    OpenAPIInfo                             `json:"info"`
+	Paths   map[string]map[string]*openAPIOperation `json:"paths"`
+}
+
+// openAPIOperation 单个接口
+type openAPIOperation struct {
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+	Servers     []openAPIServer            `json:"servers,omitempty"` // 限定域名的路由, 为空时不限域名
+}
+
+// openAPIServer 接口所在的服务地址
+type openAPIServer struct {
+	URL       string                           `json:"url"`
+	Variables map[string]openAPIServerVariable `json:"variables,omitempty"`
+}
+
+// openAPIServerVariable 服务地址中的变量, 如 {tenant}.example.com 中的 tenant
+type openAPIServerVariable struct {
+	Default string `json:"default"`
+}
+
+// openAPIParameter 路径或查询参数
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required"`
+	Schema      map[string]any `json:"schema"`
+}
+
+// openAPIRequestBody 请求体
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+// openAPIResponse 响应
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+// openAPIMediaType 内容类型对应的结构
+type openAPIMediaType struct {
+	Schema map[string]any `json:"schema"`
+}
+
+var (
+	fileHeaderType = reflect.TypeOf(multipart.FileHeader{})
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// swaggerAssets Swagger-UI 静态资源, 取自 swagger-ui-dist 5.18.2, 不依赖外部 CDN
+//
+//go:embed swagger-ui
+var swaggerAssets embed.FS
+
+// swaggerFiles Swagger-UI 静态资源的地址和文件, 地址不带扩展名, 避免被静态文件服务按后缀拦截
+var swaggerFiles = [][2]string{
+	{"css", "swagger-ui/swagger-ui.css"},
+	{"js", "swagger-ui/swagger-ui-bundle.js"},
+}
+
+const swaggerTemplate = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+  <meta charset="utf-8">
+  <title>%[1]s</title>
+  <link rel="stylesheet" href="%[2]s/css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="%[2]s/js"></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({url: %[3]q, dom_id: "#swagger-ui"});
+    };
+  </script>
+</body>
+</html>`
+
+// OpenAPI 根据已注册的路由生成 OpenAPI 3.1 接口文档
+func (engine *Engine) OpenAPI() ([]byte, error) {
+	doc := openAPIDocument{
+		OpenAPI: "3.1.0",
+		Info:    engine.OpenAPIInfo,
+		Paths:   make(map[string]map[string]*openAPIOperation),
+	}
+	for _, rt := range engine.routes {
+		if rt.hidden {
+			continue
+		}
+		docPath, parameters := openAPIPath(rt.path)
+		operation := &openAPIOperation{
+			Summary:     rt.summary,
+			Description: rt.description,
+			Tags:        rt.tags,
+			Parameters:  parameters,
+			Responses: map[string]openAPIResponse{
+				"200": {
+					Description: "成功",
+					Content: map[string]openAPIMediaType{
+						"application/json": {Schema: openAPIResult(rt.resType)},
+					},
+				},
+			},
+		}
+		if rt.reqType != nil {
+			builder := &schemaBuilder{tag: "p", visited: make(map[reflect.Type]bool)}
+			if rt.method == http.MethodGet || rt.method == http.MethodHead {
+				operation.Parameters = append(operation.Parameters, builder.queryParameters(rt.reqType)...)
+			} else {
+				operation.RequestBody = builder.requestBody(rt.reqType)
+			}
+		}
+		operation.Parameters = append(operation.Parameters, openAPIHeaders(rt.headers)...)
+		if rt.host != "" {
+			operation.Servers = []openAPIServer{openAPIHost(rt.host)}
+		}
+		if doc.Paths[docPath] == nil {
+			doc.Paths[docPath] = make(map[string]*openAPIOperation)
+		}
+		method := strings.ToLower(rt.method)
+		// 同一路径和请求方法下按域名或请求头区分的路由合并为一个接口
+		if existing, ok := doc.Paths[docPath][method]; ok {
+			existing.merge(operation)
+			continue
+		}
+		doc.Paths[docPath][method] = operation
+	}
+	return json.Marshal(doc)
+}
+
+// registerOpenAPI 注册接口文档和 Swagger-UI 路由
+func (engine *Engine) registerOpenAPI() {
+	if engine.OpenAPIPath == "" {
+		return
+	}
+	engine.GET(engine.OpenAPIPath, func(ctx *Context) {
+		data, err := engine.OpenAPI()
+		if err != nil {
+			ctx.Fail("接口文档生成失败", FailOption{
+				StatusCode: http.StatusInternalServerError,
+				ErrorCode:  ErrorCode.EXCEPTION,
+			})
+			return
+		}
+		ctx.Response.Header().Set("Content-Type", "application/json; charset=utf-8")
+		ctx.Response.Write(data)
+	}).hide()
+	if engine.SwaggerPath != "" {
+		assetsPath := path.Join(engine.SwaggerPath, "assets")
+		engine.GET(engine.SwaggerPath, func(ctx *Context) {
+			ctx.HTML(fmt.Sprintf(swaggerTemplate, engine.OpenAPIInfo.Title, assetsPath, engine.OpenAPIPath))
+		}).hide()
+		for _, asset := range swaggerFiles {
+			file := asset[1]
+			engine.GET(path.Join(assetsPath, asset[0]), func(ctx *Context) {
+				http.ServeFileFS(ctx.Response, ctx.Request, swaggerAssets, file)
+			}).hide()
+		}
+	}
+}
+
+// openAPIHeaders 将路由的请求头条件转换为请求头参数, 条件有值时作为枚举值
+func openAPIHeaders(headers []headerMatch) []openAPIParameter {
+	var parameters []openAPIParameter
+	for _, h := range headers {
+		schema := map[string]any{"type": "string"}
+		if h.value != "" {
+			schema["enum"] = []any{h.value}
+		}
+		parameters = append(parameters, openAPIParameter{
+			Name:     h.key,
+			In:       "header",
+			Required: true,
+			Schema:   schema,
+		})
+	}
+	return parameters
+}
+
+// openAPIHost 将域名匹配规则转换为服务地址, :name 和 * 转换为地址变量
+func openAPIHost(host string) openAPIServer {
+	server := openAPIServer{}
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		name := ""
+		switch {
+		case strings.HasPrefix(label, ":"):
+			name = label[1:]
+		case label == "*":
+			name = "label" + strconv.Itoa(i)
+		default:
+			continue
+		}
+		if server.Variables == nil {
+			server.Variables = make(map[string]openAPIServerVariable)
+		}
+		server.Variables[name] = openAPIServerVariable{Default: name}
+		labels[i] = "{" + name + "}"
+	}
+	server.URL = "//" + strings.Join(labels, ".")
+	return server
+}
+
+// merge 合并同一路径和请求方法下的另一个路由
+// 任一路由不限域名时合并后不限域名, 只有部分路由要求的请求头改为非必填, 请求头的枚举值取并集
+func (o *openAPIOperation) merge(other *openAPIOperation) {
+	if o.Summary == "" {
+		o.Summary = other.Summary
+	}
+	if o.Description == "" {
+		o.Description = other.Description
+	}
+	if o.RequestBody == nil {
+		o.RequestBody = other.RequestBody
+	}
+	for _, tag := range other.Tags {
+		if !tkUtil.StringInSlice(tag, o.Tags) {
+			o.Tags = append(o.Tags, tag)
+		}
+	}
+	if o.Servers == nil || other.Servers == nil {
+		o.Servers = nil
+	} else {
+		for _, server := range other.Servers {
+			if !containsServer(o.Servers, server.URL) {
+				o.Servers = append(o.Servers, server)
+			}
+		}
+	}
+	for i := range o.Parameters {
+		if o.Parameters[i].In == "header" && indexParameter(other.Parameters, o.Parameters[i]) < 0 {
+			o.Parameters[i].Required = false
+		}
+	}
+	for _, parameter := range other.Parameters {
+		i := indexParameter(o.Parameters, parameter)
+		if i < 0 {
+			if parameter.In == "header" {
+				parameter.Required = false
+			}
+			o.Parameters = append(o.Parameters, parameter)
+			continue
+		}
+		if parameter.In != "header" {
+			continue
+		}
+		existing := &o.Parameters[i]
+		existing.Required = existing.Required && parameter.Required
+		enum, ok := existing.Schema["enum"].([]any)
+		otherEnum, otherOk := parameter.Schema["enum"].([]any)
+		if !ok || !otherOk {
+			// 任一路由只要求请求头存在时不限制取值
+			delete(existing.Schema, "enum")
+			continue
+		}
+		for _, value := range otherEnum {
+			if !containsValue(enum, value) {
+				enum = append(enum, value)
+			}
+		}
+		existing.Schema["enum"] = enum
+	}
+}
+
+// indexParameter 按名称和位置查找参数, 不存在返回 -1
+func indexParameter(parameters []openAPIParameter, parameter openAPIParameter) int {
+	for i, p := range parameters {
+		if p.In == parameter.In && strings.EqualFold(p.Name, parameter.Name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// containsServer 服务地址是否已存在
+func containsServer(servers []openAPIServer, url string) bool {
+	for _, server := range servers {
+		if server.URL == url {
+			return true
+		}
+	}
+	return false
+}
+
+// containsValue 枚举值是否已存在
+func containsValue(values []any, value any) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// hide 不在接口文档中展示
+func (r *Route) hide() *Route {
+	for _, rt := range r.routes {
+		rt.hidden = true
+	}
+	return r
+}
+
+// openAPIPath 将 /user/:id /user/{id:int} 转换为 /user/{id} 并生成路径参数
+func openAPIPath(routePath string) (string, []openAPIParameter) {
+	var parameters []openAPIParameter
+	segments := strings.Split(routePath, "/")
+	for i, segment := range segments {
+		name, constraint, ok := parseParam(segment)
+		if !ok && strings.HasPrefix(segment, "*") {
+			name, ok = segment[1:], true
+		}
+		if !ok {
+			continue
+		}
+		segments[i] = "{" + name + "}"
+		parameters = append(parameters, openAPIParameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   constraintSchema(constraint),
+		})
+	}
+	return strings.Join(segments, "/"), parameters
+}
+
+// constraintSchema 路由参数约束对应的结构
+func constraintSchema(constraint string) map[string]any {
+	switch constraint {
+	case "":
+		return map[string]any{"type": "string"}
+	case "int":
+		return map[string]any{"type": "integer"}
+	case "uint":
+		return map[string]any{"type": "integer", "minimum": 0}
+	case "uuid":
+		return map[string]any{"type": "string", "format": "uuid"}
+	case "alpha":
+		return map[string]any{"type": "string", "pattern": "^[a-zA-Z]+$"}
+	case "alnum":
+		return map[string]any{"type": "string", "pattern": "^[a-zA-Z0-9]+$"}
+	}
+	return map[string]any{"type": "string", "pattern": "^(?:" + constraint + ")$"}
+}
+
+// openAPIResult 统一返回结果的结构
+func openAPIResult(resType reflect.Type) map[string]any {
+	data := map[string]any{}
+	if resType != nil {
+		builder := &schemaBuilder{tag: "json", visited: make(map[reflect.Type]bool)}
+		data = builder.schema(resType)
+	}
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"code":    map[string]any{"type": "integer"},
+			"message": map[string]any{"type": "string"},
+			"data":    data,
+		},
+	}
+}
+
+// schemaBuilder 根据结构体生成 JSON Schema
+type schemaBuilder struct {
+	tag     string // 字段名取值的标签, 查询参数和表单用 p, JSON 请求体和响应用 json
+	visited map[reflect.Type]bool
+}
+
+// queryParameters 将请求结构体字段转换为查询参数
+func (b *schemaBuilder) queryParameters(t reflect.Type) []openAPIParameter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var parameters []openAPIParameter
+	b.eachField(t, func(name string, schema map[string]any, required bool) {
+		description, _ := schema["description"].(string)
+		delete(schema, "description")
+		parameters = append(parameters, openAPIParameter{
+			Name:        name,
+			In:          "query",
+			Description: description,
+			Required:    required,
+			Schema:      schema,
+		})
+	})
+	return parameters
+}
+
+// requestBody 将请求结构体转换为请求体, 表单按 b.tag 取字段名, JSON 与 json.Unmarshal 一致按 json 标签取字段名
+func (b *schemaBuilder) requestBody(t reflect.Type) *openAPIRequestBody {
+	jsonBuilder := &schemaBuilder{tag: "json", visited: make(map[reflect.Type]bool)}
+	formType := "application/x-www-form-urlencoded"
+	if hasFileField(t) {
+		formType = "multipart/form-data"
+	}
+	return &openAPIRequestBody{
+		Required: true,
+		Content: map[string]openAPIMediaType{
+			"application/json": {Schema: jsonBuilder.schema(t)},
+			formType:           {Schema: b.schema(t)},
+		},
+	}
+}
+
+// schema 生成类型对应的 JSON Schema
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case fileHeaderType:
+		return map[string]any{"type": "string", "format": "binary"}
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if b.visited[t] {
+			return map[string]any{"type": "object"}
+		}
+		b.visited[t] = true
+		defer delete(b.visited, t)
+		properties := make(map[string]any)
+		var required []string
+		b.eachField(t, func(name string, schema map[string]any, isRequired bool) {
+			properties[name] = schema
+			if isRequired {
+				required = append(required, name)
+			}
+		})
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]any{}
+}
+
+// eachField 遍历结构体导出字段,匿名结构体字段会被展开
+func (b *schemaBuilder) eachField(t reflect.Type, fn func(name string, schema map[string]any, required bool)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get(b.tag) == "" {
+			b.eachField(field.Type, fn)
+			continue
+		}
+		name := b.fieldName(field)
+		if name == "-" {
+			continue
+		}
+		schema := b.schema(field.Type)
+		required := applyValidateRules(schema, field.Tag.Get("v"))
+		if msg := field.Tag.Get("msg"); msg != "" {
+			schema["description"] = msg
+		}
+		fn(name, schema, required)
+	}
+}
+
+// fieldName 字段在文档中的名称, 优先取 tag 标签, 其次 json 标签, 最后为字段名
+func (b *schemaBuilder) fieldName(field reflect.StructField) string {
+	for _, tag := range []string{b.tag, "json"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// applyValidateRules 将 v 标签中的验证规则转换为 JSON Schema 约束,返回是否必填
+func applyValidateRules(schema map[string]any, rules string) (required bool) {
+	if rules == "" {
+		return false
+	}
+	schemaType, _ := schema["type"].(string)
+	for _, rule := range strings.Split(rules, ",") {
+		name, value, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			var keys []string
+			switch schemaType {
+			case "string":
+				keys = map[string][]string{"min": {"minLength"}, "max": {"maxLength"}, "len": {"minLength", "maxLength"}}[name]
+			case "array":
+				keys = map[string][]string{"min": {"minItems"}, "max": {"maxItems"}, "len": {"minItems", "maxItems"}}[name]
+			case "integer", "number":
+				keys = map[string][]string{"min": {"minimum"}, "max": {"maximum"}, "len": {"minimum", "maximum"}}[name]
+			}
+			for _, key := range keys {
+				schema[key] = n
+			}
+		case "gt", "gte", "lt", "lte":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil || (schemaType != "integer" && schemaType != "number") {
+				continue
+			}
+			key := map[string]string{"gt": "exclusiveMinimum", "gte": "minimum", "lt": "exclusiveMaximum", "lte": "maximum"}[name]
+			schema[key] = n
+		case "oneof":
+			var enum []any
+			for _, item := range strings.Fields(value) {
+				if n, err := strconv.ParseFloat(item, 64); err == nil && (schemaType == "integer" || schemaType == "number") {
+					enum = append(enum, n)
+				} else {
+					enum = append(enum, item)
+				}
+			}
+			schema["enum"] = enum
+		case "email":
+			schema["format"] = "email"
+		case "url", "uri":
+			schema["format"] = "uri"
+		case "uuid", "uuid4":
+			schema["format"] = "uuid"
+		case "ip", "ipv4":
+			schema["format"] = "ipv4"
+		case "ipv6":
+			schema["format"] = "ipv6"
+		}
+	}
+	return required
+}
+
+// hasFileField 结构体中是否包含上传文件字段
+func hasFileField(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i).Type
+		for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+		if ft == fileHeaderType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package thinko
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type openAPIUserReq struct {
+	Name  string `p:"name" json:"userName" v:"required,max=20" msg:"用户名"`
+	Age   int    `p:"age" v:"gte=0,lte=150"`
+	Email string `p:"email" json:"email" v:"email"`
+}
+
+type openAPIUserRes struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// openAPIDoc 生成接口文档并解析为 map, 方便按路径取值
+func openAPIDoc(t *testing.T, engine *Engine) map[string]any {
+	t.Helper()
+	data, err := engine.OpenAPI()
+	if err != nil {
+		t.Fatalf("生成接口文档失败: %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("接口文档不是合法的 JSON: %v", err)
+	}
+	return doc
+}
+
+// lookup 按 key 依次取出嵌套的值
+func lookup(t *testing.T, value any, keys ...string) any {
+	t.Helper()
+	for _, key := range keys {
+		m, ok := value.(map[string]any)
+		if !ok {
+			t.Fatalf("%s 的上一级不是对象: %v", key, value)
+		}
+		if value, ok = m[key]; !ok {
+			t.Fatalf("缺少 %s: %v", key, m)
+		}
+	}
+	return value
+}
+
+func TestOpenAPI(t *testing.T) {
+	engine := New()
+	engine.OpenAPIInfo = OpenAPIInfo{Title: "测试", Version: "1.0.0"}
+	engine.GET("/user/list", echo("list")).Summary("用户列表").Tags("用户").Request(openAPIUserReq{}).Response([]openAPIUserRes{})
+	engine.POST("/user", echo("create")).Request(openAPIUserReq{}).Response(openAPIUserRes{})
+	engine.GET("/user/:id/*path", echo("files"))
+	engine.GET("/internal", echo("internal")).hide()
+
+	doc := openAPIDoc(t, engine)
+	if doc["openapi"] != "3.1.0" || lookup(t, doc, "info", "title") != "测试" {
+		t.Fatalf("文档基本信息不正确: %v %v", doc["openapi"], doc["info"])
+	}
+
+	list := lookup(t, doc, "paths", "/user/list", "get")
+	if lookup(t, list, "summary") != "用户列表" {
+		t.Fatalf("摘要不正确: %v", list)
+	}
+	parameters := lookup(t, list, "parameters").([]any)
+	if len(parameters) != 3 {
+		t.Fatalf("GET 请求结构体应生成 3 个查询参数, 实际为 %v", parameters)
+	}
+	name := parameters[0].(map[string]any)
+	if name["name"] != "name" || name["in"] != "query" || name["required"] != true || name["description"] != "用户名" {
+		t.Fatalf("查询参数不正确: %v", name)
+	}
+	if lookup(t, name, "schema", "maxLength") != float64(20) {
+		t.Fatalf("max 规则应转换为 maxLength: %v", name)
+	}
+	items := lookup(t, list, "responses", "200", "content", "application/json", "schema", "properties", "data", "items")
+	if lookup(t, items, "properties", "id", "type") != "integer" {
+		t.Fatalf("响应结构不正确: %v", items)
+	}
+
+	content := lookup(t, doc, "paths", "/user", "post", "requestBody", "content")
+	body := lookup(t, content, "application/json", "schema")
+	if lookup(t, body, "properties", "Age", "maximum") != float64(150) {
+		t.Fatalf("lte 规则应转换为 maximum: %v", body)
+	}
+	if lookup(t, body, "properties", "email", "format") != "email" {
+		t.Fatalf("email 规则应转换为 format: %v", body)
+	}
+	// JSON 请求体按 json 标签取字段名, 表单按 p 标签
+	if _, ok := lookup(t, body, "properties").(map[string]any)["userName"]; !ok {
+		t.Fatalf("JSON 请求体应使用 json 标签: %v", body)
+	}
+	form := lookup(t, content, "application/x-www-form-urlencoded", "schema")
+	if _, ok := lookup(t, form, "properties").(map[string]any)["name"]; !ok {
+		t.Fatalf("表单请求体应使用 p 标签: %v", form)
+	}
+
+	files := lookup(t, doc, "paths", "/user/{id}/{path}", "get", "parameters").([]any)
+	if len(files) != 2 || files[0].(map[string]any)["in"] != "path" {
+		t.Fatalf("路径参数不正确: %v", files)
+	}
+
+	if _, ok := lookup(t, doc, "paths").(map[string]any)["/internal"]; ok {
+		t.Fatal("隐藏的路由不应出现在接口文档中")
+	}
+}
+
+func TestOpenAPIRoute(t *testing.T) {
+	engine := New()
+	engine.SwaggerPath = "/swagger"
+	engine.GET("/ping", echo("pong"))
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api.json", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatalf("接口文档响应不正确: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("接口文档不是合法的 JSON: %v", err)
+	}
+	paths := doc["paths"].(map[string]any)
+	if _, ok := paths["/ping"]; !ok {
+		t.Fatalf("缺少 /ping: %v", paths)
+	}
+	if _, ok := paths["/api.json"]; ok {
+		t.Fatal("接口文档自身不应出现在文档中")
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/swagger", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Swagger-UI 状态码为 %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "https://") {
+		t.Fatalf("Swagger-UI 不应加载外部资源: %s", w.Body.String())
+	}
+
+	assets := map[string]string{
+		"/swagger/assets/css": "text/css; charset=utf-8",
+		"/swagger/assets/js":  "text/javascript; charset=utf-8",
+	}
+	for asset, contentType := range assets {
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, asset, nil))
+		if w.Code != http.StatusOK || w.Body.Len() == 0 || w.Header().Get("Content-Type") != contentType {
+			t.Fatalf("%s 响应为 %d %s", asset, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/swagger/assets/LICENSE", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("未公开的资源状态码为 %d, 期望 404", w.Code)
+	}
+}
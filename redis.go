@@ -2,9 +2,11 @@ package thinko
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/redis/go-redis/v9"
 	tkConfig "github.com/watsonhaw5566/thinko/config"
+	"github.com/watsonhaw5566/thinko/trace"
 	"net/http"
 	"sync"
 	"time"
@@ -64,6 +66,7 @@ func RDb(source ...RSource) *TRdb {
 			Error:     err,
 		})
 	}
+	rdb.AddHook(tracingHook{})
 	rdbInstance.Store(key, rdb)
 	return &TRdb{
 		instance: rdb,
@@ -71,6 +74,66 @@ func RDb(source ...RSource) *TRdb {
 	}
 }
 
+// WithContext 设置上下文, 上下文取消或超时时中断命令执行, 同时用于关联请求的追踪信息
+// 可以直接传入 *thinko.Context, think.RDb().WithContext(ctx).Get("key")
+func (db *TRdb) WithContext(ctx context.Context) *TRdb {
+	return &TRdb{
+		instance: db.instance,
+		ctx:      ctx,
+	}
+}
+
+// tracingHook 追踪 Redis 命令
+type tracingHook struct{}
+
+// DialHook 建立连接不追踪
+func (tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook 追踪单条命令
+func (tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := trace.Start(ctx, "redis "+cmd.Name(), trace.SpanKindClient)
+		span.SetAttribute("db.system", "redis")
+		span.SetAttribute("db.operation", cmd.Name())
+		err := next(ctx, cmd)
+		if !errors.Is(err, redis.Nil) {
+			span.RecordError(err)
+		}
+		span.End()
+		return err
+	}
+}
+
+// ProcessPipelineHook 追踪管道命令
+func (tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := trace.Start(ctx, "redis pipeline", trace.SpanKindClient)
+		span.SetAttribute("db.system", "redis")
+		span.SetAttribute("db.redis.num_cmd", len(cmds))
+		err := next(ctx, cmds)
+		if !errors.Is(err, redis.Nil) {
+			span.RecordError(err)
+		}
+		span.End()
+		return err
+	}
+}
+
+// CloseRDb 关闭全部 Redis 客户端, 一般在服务关闭钩子中调用
+func CloseRDb() error {
+	var errs []error
+	rdbInstance.Range(func(key, value any) bool {
+		if err := value.(*redis.Client).Close(); err != nil {
+			errs = append(errs, err)
+		}
+		rdbInstance.Delete(key)
+		return true
+	})
+	return errors.Join(errs...)
+}
+
 // ----字符串----
 
 // Get 获取存储在键上的字符串值
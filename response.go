@@ -0,0 +1,114 @@
+package thinko
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter 响应写入器, 在 http.ResponseWriter 基础上记录状态码、响应大小和是否已写入
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+	http.Pusher
+
+	// Status 响应状态码, 未写入时为 200
+	Status() int
+	// Size 已写入的响应体字节数
+	Size() int
+	// Written 是否已写入响应头
+	Written() bool
+	// Unwrap 获取原始的 http.ResponseWriter, 供 http.ResponseController 使用
+	Unwrap() http.ResponseWriter
+}
+
+// responseWriter ResponseWriter 的实现, 嵌在 Context 中随 Context 复用
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	size    int
+	written bool
+}
+
+// reset 重置为新的请求
+func (w *responseWriter) reset(writer http.ResponseWriter) {
+	w.ResponseWriter = writer
+	w.status = http.StatusOK
+	w.size = 0
+	w.written = false
+}
+
+// WriteHeader 写入状态码, 已写入后再次调用会被忽略, 避免 superfluous WriteHeader 警告
+func (w *responseWriter) WriteHeader(code int) {
+	if w.written {
+		return
+	}
+	// 1xx 信息响应可以发送多次, 不算写入
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+	w.written = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write 写入响应体, 未写入状态码时使用 200
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(w.status)
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+// Status 响应状态码
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+// Size 已写入的响应体字节数
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+// Written 是否已写入响应头
+func (w *responseWriter) Written() bool {
+	return w.written
+}
+
+// Flush 将缓冲的数据发送给客户端
+func (w *responseWriter) Flush() {
+	if !w.written {
+		w.WriteHeader(w.status)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack 接管连接, 如 WebSocket
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("ResponseWriter 不支持 Hijack")
+	}
+	w.written = true
+	return hijacker.Hijack()
+}
+
+// Push HTTP/2 服务端推送, 不支持时返回 http.ErrNotSupported
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap 获取原始的 http.ResponseWriter
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
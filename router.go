@@ -3,7 +3,10 @@ package thinko
 import (
 	"fmt"
 	"net/http"
+	"net/textproto"
 	"path"
+	"reflect"
+	"strings"
 )
 
 // HandlerFunc 定义http执行函数类型
@@ -12,97 +15,303 @@ type HandlerFunc func(ctx *Context)
 // MiddlewareFunc 定义中间件函数类型
 type MiddlewareFunc func() HandlerFunc
 
-// RouterGroup 定义分组路由结构体
+// RouterGroup 定义分组路由结构体, 分组之间构成树, 子分组继承上级分组的中间件
 type routerGroup struct {
-	basePath           string
-	handlerFuncMap     map[string]map[string]HandlerFunc
-	middlewaresFuncMap map[string]map[string][]MiddlewareFunc
-	middlewares        []MiddlewareFunc
-	groupMiddlewares   []MiddlewareFunc
+	basePath    string
+	engine      *Engine
+	parent      *routerGroup
+	middlewares []MiddlewareFunc
+	host        string
+	headers     []headerMatch
 }
 
-// Use 添加中间件
+// Route 路由注册结果,可链式补充接口文档信息
+type Route struct {
+	engine *Engine
+	routes []*route
+}
+
+// Use 添加中间件, 引擎上添加的是全局中间件, 分组上添加的只作用于之后在该分组及其子分组注册的路由
 func (group *routerGroup) Use(middlewareFunc ...MiddlewareFunc) {
 	group.middlewares = append(group.middlewares, middlewareFunc...)
+	if group.parent == nil {
+		group.engine.recompile()
+	}
 }
 
 // Group 分组路由
 func (group *routerGroup) Group(relativePath string, middlewareFunc ...MiddlewareFunc) *routerGroup {
 	newRouterGroup := &routerGroup{
-		basePath:           path.Join(group.basePath, relativePath),
-		handlerFuncMap:     group.handlerFuncMap,
-		middlewaresFuncMap: group.middlewaresFuncMap,
-		groupMiddlewares:   middlewareFunc,
+		basePath:    path.Join(group.basePath, relativePath),
+		engine:      group.engine,
+		parent:      group,
+		middlewares: append([]MiddlewareFunc{}, middlewareFunc...),
 	}
 	return newRouterGroup
 }
 
+// Host 只匹配指定域名的分组, 支持 :name 将该段子域名捕获为路由参数, * 匹配任意一段
+// engine.Host("admin.example.com"), engine.Host(":tenant.example.com")
+// 匹配时忽略请求的端口, 规则中不能包含端口
+func (group *routerGroup) Host(host string, middlewareFunc ...MiddlewareFunc) *routerGroup {
+	newRouterGroup := group.Group("", middlewareFunc...)
+	newRouterGroup.host = normalizeHost(host)
+	return newRouterGroup
+}
+
+// normalizeHost 将域名规则中的固定段转为小写, 参数名保持原样
+func normalizeHost(host string) string {
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		switch {
+		case label == "" || label == ":":
+			panic(routeException(fmt.Sprintf("域名规则格式错误 [%s]", host)))
+		case strings.Contains(label[1:], ":") || (label[0] != ':' && strings.Contains(label, ":")):
+			panic(routeException(fmt.Sprintf("域名规则不能包含端口 [%s]", host)))
+		case label[0] != ':':
+			labels[i] = strings.ToLower(label)
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// Header 只匹配请求头为指定值的分组, value 为空时只要求请求头存在, 如 group.Header("Accept-Version", "2")
+func (group *routerGroup) Header(key string, value string, middlewareFunc ...MiddlewareFunc) *routerGroup {
+	newRouterGroup := group.Group("", middlewareFunc...)
+	newRouterGroup.headers = []headerMatch{{key: textproto.CanonicalMIMEHeaderKey(key), value: value}}
+	return newRouterGroup
+}
+
 // GET GET请求
-func (group *routerGroup) GET(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	group.handler(http.MethodGet, relativePath, handlerFunc, middlewareFunc...)
+func (group *routerGroup) GET(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return group.handle(http.MethodGet, relativePath, handlerFunc, middlewareFunc...)
 }
 
 // POST POST请求
-func (group *routerGroup) POST(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	group.handler(http.MethodPost, relativePath, handlerFunc, middlewareFunc...)
+func (group *routerGroup) POST(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return group.handle(http.MethodPost, relativePath, handlerFunc, middlewareFunc...)
 }
 
 // DELETE DELETE请求
-func (group *routerGroup) DELETE(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	group.handler(http.MethodDelete, relativePath, handlerFunc, middlewareFunc...)
+func (group *routerGroup) DELETE(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return group.handle(http.MethodDelete, relativePath, handlerFunc, middlewareFunc...)
 }
 
 // PUT PUT请求
-func (group *routerGroup) PUT(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	group.handler(http.MethodPut, relativePath, handlerFunc, middlewareFunc...)
+func (group *routerGroup) PUT(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return group.handle(http.MethodPut, relativePath, handlerFunc, middlewareFunc...)
 }
 
 // PATCH PATCH请求
-func (group *routerGroup) PATCH(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	group.handler(http.MethodPatch, relativePath, handlerFunc, middlewareFunc...)
+func (group *routerGroup) PATCH(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return group.handle(http.MethodPatch, relativePath, handlerFunc, middlewareFunc...)
 }
 
 // OPTIONS OPTIONS请求
-func (group *routerGroup) OPTIONS(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	group.handler(http.MethodOptions, relativePath, handlerFunc, middlewareFunc...)
+func (group *routerGroup) OPTIONS(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return group.handle(http.MethodOptions, relativePath, handlerFunc, middlewareFunc...)
 }
 
 // HEAD HEAD请求
-func (group *routerGroup) HEAD(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	group.handler(http.MethodHead, relativePath, handlerFunc, middlewareFunc...)
+func (group *routerGroup) HEAD(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return group.handle(http.MethodHead, relativePath, handlerFunc, middlewareFunc...)
 }
 
 // ALL ALL请求
-func (group *routerGroup) ALL(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
+func (group *routerGroup) ALL(relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
 	methods := []string{http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodPut, http.MethodPatch, http.MethodOptions, http.MethodHead}
+	r := &Route{engine: group.engine}
 	for _, method := range methods {
-		group.handler(method, relativePath, handlerFunc, middlewareFunc...)
+		rt := group.handler(method, relativePath, handlerFunc, middlewareFunc...)
+		// HEAD 和 OPTIONS 只是附带注册, 不出现在接口文档中
+		if method == http.MethodHead || method == http.MethodOptions {
+			rt.hidden = true
+		}
+		r.routes = append(r.routes, rt)
+	}
+	return r
+}
+
+// Mount 将 http.Handler 挂载到指定前缀下, 如 pprof, 第三方 SDK 或另一个 thinko.Engine
+// 所有请求方法都会转发, 包括 PROPFIND 等未注册的方法, 转发前去掉前缀, 挂载的处理器同样经过分组的中间件
+func (group *routerGroup) Mount(prefix string, handler http.Handler, middlewareFunc ...MiddlewareFunc) *Route {
+	mountPath := path.Join(group.basePath, prefix, "*mountPath")
+	r := group.ALL(path.Join(prefix, "*mountPath"), func(ctx *Context) {
+		req := new(http.Request)
+		*req = *ctx.Request
+		u := *ctx.Request.URL
+		u.Path = ctx.Param("mountPath")
+		u.RawPath = ""
+		req.URL = &u
+		req.RequestURI = u.RequestURI()
+		handler.ServeHTTP(ctx.Response, req)
+	}, middlewareFunc...)
+	for _, rt := range r.routes {
+		rt.handlerName = fmt.Sprintf("%T", handler)
+	}
+	// 其他请求方法匹配不到路由时使用 GET 路由的处理链
+	group.engine.mounts.addRoute(mountPath, r.routes[0])
+	return r.hide()
+}
+
+// WrapH 将 http.Handler 转换为 HandlerFunc, 不去掉路由前缀
+func WrapH(handler http.Handler) HandlerFunc {
+	return func(ctx *Context) {
+		handler.ServeHTTP(ctx.Response, ctx.Request)
+	}
+}
+
+// WrapF 将 http.HandlerFunc 转换为 HandlerFunc, 不去掉路由前缀
+func WrapF(handlerFunc http.HandlerFunc) HandlerFunc {
+	return func(ctx *Context) {
+		handlerFunc(ctx.Response, ctx.Request)
+	}
+}
+
+// Router 可注册路由的 Engine 或分组, 供 Handle 使用
+type Router interface {
+	group() *routerGroup
+}
+
+// group 获取分组本身, Engine 通过嵌入的根分组实现 Router
+func (group *routerGroup) group() *routerGroup {
+	return group
+}
+
+// Handle 绑定结构体控制器, 控制器格式为 func(ctx *thinko.Context, req *api.XxxReq) (res api.XxxRes, err error)
+// 请求参数自动调用 BindStructValidate 映射和验证, res 通过 Success 输出
+// err 为 *Error 时按其信息输出, 其他错误记录日志后统一输出 500 服务异常, 避免泄露内部信息
+func Handle[Req, Res any](router Router, method string, relativePath string, controller func(ctx *Context, req *Req) (Res, error), middlewareFunc ...MiddlewareFunc) *Route {
+	group := router.group()
+	reqType := reflect.TypeFor[Req]()
+	if reqType.Kind() != reflect.Struct {
+		panic(routeException(fmt.Sprintf("控制器请求参数必须为结构体 [%s][%s]", method, path.Join(group.basePath, relativePath))))
+	}
+	r := group.handle(method, relativePath, func(ctx *Context) {
+		req := new(Req)
+		ctx.BindStructValidate(req)
+		res, err := controller(ctx, req)
+		if err != nil {
+			ctx.fail(err)
+			return
+		}
+		ctx.Success(res)
+	}, middlewareFunc...)
+	for _, rt := range r.routes {
+		rt.reqType = reqType
+		rt.resType = reflect.TypeFor[Res]()
+		rt.handlerName = funcName(controller)
 	}
+	return r
 }
 
-// Bind 绑定控制器,可以用api结构体的方式定义路由
-func (group *routerGroup) Bind() {
-	// var req api.SayHelloReq
-	// ctx.BindStructValidate(&req)
-	// 这功能实际就是省了这两行代码,多加一个参数,有空再写吧
+// handle 注册单个请求方法的路由
+func (group *routerGroup) handle(method string, relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return &Route{
+		engine: group.engine,
+		routes: []*route{group.handler(method, relativePath, handlerFunc, middlewareFunc...)},
+	}
 }
 
-// handler http绑定的函数
-func (group *routerGroup) handler(method string, relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
+// handler http绑定的函数, 路径支持 :name 命名参数和 *name 通配参数
+func (group *routerGroup) handler(method string, relativePath string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *route {
 	mergePath := path.Join(group.basePath, relativePath)
-	_, ok := group.handlerFuncMap[mergePath]
+	root, ok := group.engine.trees[method]
 	if !ok {
-		group.handlerFuncMap[mergePath] = make(map[string]HandlerFunc)
-		group.middlewaresFuncMap[mergePath] = make(map[string][]MiddlewareFunc)
-	}
-	_, ok = group.handlerFuncMap[mergePath][method]
-	if ok {
-		panic(Exception{
-			StateCode: http.StatusInternalServerError,
-			ErrorCode: ErrorCode.EXCEPTION,
-			Message:   fmt.Sprintf("路由重复 [%s][%s]", mergePath, method),
-		})
-	}
-	group.handlerFuncMap[mergePath][method] = handlerFunc
-	group.middlewaresFuncMap[mergePath][method] = append(append(group.middlewaresFuncMap[mergePath][method], group.groupMiddlewares...), middlewareFunc...)
+		root = &node{}
+		group.engine.trees[method] = root
+	}
+	host, headers := group.combineConditions()
+	rt := &route{
+		method:      method,
+		path:        mergePath,
+		handler:     handlerFunc,
+		handlerName: funcName(handlerFunc),
+		middlewares: append(group.combineMiddlewares(), middlewareFunc...),
+		host:        host,
+		headers:     headers,
+	}
+	root.addRoute(mergePath, rt)
+	group.engine.routes = append(group.engine.routes, rt)
+	if n := countParams(mergePath, host); n > group.engine.maxParams {
+		group.engine.maxParams = n
+	}
+	group.engine.recompile()
+	return rt
+}
+
+// combineMiddlewares 按从上级到下级的顺序合并分组中间件, 全局中间件在请求时单独处理
+func (group *routerGroup) combineMiddlewares() []MiddlewareFunc {
+	var groups []*routerGroup
+	for g := group; g.parent != nil; g = g.parent {
+		groups = append(groups, g)
+	}
+	var middlewares []MiddlewareFunc
+	for i := len(groups) - 1; i >= 0; i-- {
+		middlewares = append(middlewares, groups[i].middlewares...)
+	}
+	return middlewares
+}
+
+// combineConditions 合并分组的匹配条件, 域名取最近设置的分组, 请求头条件逐级累加
+func (group *routerGroup) combineConditions() (host string, headers []headerMatch) {
+	for g := group; g != nil; g = g.parent {
+		if host == "" {
+			host = g.host
+		}
+		headers = append(headers, g.headers...)
+	}
+	return host, headers
+}
+
+// Name 路由命名, 可通过 Engine.URL 或模板中的 url 函数生成地址
+func (r *Route) Name(name string) *Route {
+	for _, rt := range r.routes {
+		if exist, ok := r.engine.namedRoutes[name]; ok && exist.path != rt.path {
+			panic(routeException(fmt.Sprintf("路由名称重复 [%s] 已用于 [%s]", name, exist.path)))
+		}
+		rt.name = name
+		r.engine.namedRoutes[name] = rt
+	}
+	return r
+}
+
+// Summary 接口摘要
+func (r *Route) Summary(summary string) *Route {
+	for _, rt := range r.routes {
+		rt.summary = summary
+	}
+	return r
+}
+
+// Description 接口描述
+func (r *Route) Description(description string) *Route {
+	for _, rt := range r.routes {
+		rt.description = description
+	}
+	return r
+}
+
+// Tags 接口分组标签
+func (r *Route) Tags(tags ...string) *Route {
+	for _, rt := range r.routes {
+		rt.tags = append(rt.tags, tags...)
+	}
+	return r
+}
+
+// Request 请求参数结构体,按 p v msg 标签生成接口文档, JSON 请求体按 json 标签取字段名, 如 Request(api.UserListReq{})
+func (r *Route) Request(req any) *Route {
+	for _, rt := range r.routes {
+		rt.reqType = reflect.TypeOf(req)
+	}
+	return r
+}
+
+// Response 响应数据结构体, 生成接口文档时作为统一返回结果中的 data
+func (r *Route) Response(res any) *Route {
+	for _, rt := range r.routes {
+		rt.resType = reflect.TypeOf(res)
+	}
+	return r
 }
@@ -0,0 +1,138 @@
+package thinko
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type handleUserReq struct {
+	Name string `p:"name" json:"name" v:"required"`
+}
+
+type handleUserRes struct {
+	Name string `json:"name"`
+}
+
+func TestHandle(t *testing.T) {
+	engine := New()
+	Handle(engine, http.MethodPost, "/user", func(ctx *Context, req *handleUserReq) (handleUserRes, error) {
+		switch req.Name {
+		case "exists":
+			return handleUserRes{}, &Error{StatusCode: http.StatusConflict, ErrorCode: ErrorCode.MySqlError, Message: "用户已存在", Err: errors.New("Duplicate entry 'exists'")}
+		case "broken":
+			return handleUserRes{}, errors.New("dial tcp 10.0.0.1:3306: connection refused")
+		}
+		return handleUserRes{Name: req.Name}, nil
+	})
+
+	cases := []struct {
+		name    string
+		body    string
+		code    int
+		errCode int
+		message string
+		log     string
+	}{
+		{name: "成功时通过 Success 输出", body: `{"name":"tom"}`, code: http.StatusOK, errCode: http.StatusOK, message: "ok"},
+		{name: "参数验证失败", body: `{}`, code: http.StatusUnauthorized, errCode: ErrorCode.VALIDATE},
+		{name: "返回 Error 时按其信息输出", body: `{"name":"exists"}`, code: http.StatusConflict, errCode: ErrorCode.MySqlError, message: "用户已存在", log: "Duplicate entry"},
+		{name: "其他错误只记录日志", body: `{"name":"broken"}`, code: http.StatusInternalServerError, errCode: ErrorCode.EXCEPTION, message: "服务异常", log: "connection refused"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			logs := captureLog(t)
+			r := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(c.body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			if w.Code != c.code {
+				t.Fatalf("状态码为 %d, 期望 %d: %s", w.Code, c.code, w.Body.String())
+			}
+			body := failBody(t, w)
+			if body.Code != c.errCode || (c.message != "" && body.Message != c.message) {
+				t.Fatalf("响应为 %+v", body)
+			}
+			if strings.Contains(w.Body.String(), "Duplicate") || strings.Contains(w.Body.String(), "refused") {
+				t.Fatalf("原始错误不应输出给客户端: %s", w.Body.String())
+			}
+			if c.log != "" && !strings.Contains(logs.String(), c.log) {
+				t.Fatalf("原始错误应记录日志: %s", logs.String())
+			}
+		})
+	}
+
+	var res struct {
+		Data handleUserRes `json:"data"`
+	}
+	r := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"name":"tom"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Data.Name != "tom" {
+		t.Fatalf("响应数据为 %s", w.Body.String())
+	}
+
+	schema := lookup(t, openAPIDoc(t, engine), "paths", "/user", "post", "responses", "200", "content", "application/json", "schema", "properties", "data")
+	if lookup(t, schema, "properties", "name", "type") != "string" {
+		t.Fatalf("接口文档应使用控制器的响应类型: %v", schema)
+	}
+}
+
+func TestHandleGroup(t *testing.T) {
+	engine := New()
+	Handle(engine.Group("/api"), http.MethodGet, "/user", func(ctx *Context, req *handleUserReq) (string, error) {
+		return req.Name, nil
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/user?name=tom", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"data":"tom"`) {
+		t.Fatalf("响应为 %d %s", w.Code, w.Body.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("请求参数不是结构体时应在注册时报错")
+		}
+	}()
+	Handle(engine, http.MethodGet, "/bad", func(ctx *Context, req *string) (string, error) {
+		return *req, nil
+	})
+}
+
+func TestMount(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " " + r.URL.Path + " " + r.RequestURI))
+	})
+	engine := New()
+	engine.Group("/api").Mount("/dav", handler)
+	engine.GET("/user", echo("user"))
+
+	cases := []struct {
+		name   string
+		method string
+		target string
+		code   int
+		want   string
+	}{
+		{name: "去掉前缀后转发", method: http.MethodGet, target: "/api/dav/docs/a.txt?v=1", code: http.StatusOK, want: "GET /docs/a.txt /docs/a.txt?v=1"},
+		{name: "前缀本身", method: http.MethodPost, target: "/api/dav", code: http.StatusOK, want: "POST / /"},
+		{name: "未注册的请求方法同样转发", method: "PROPFIND", target: "/api/dav/docs", code: http.StatusOK, want: "PROPFIND /docs /docs"},
+		{name: "挂载之外的路由不受影响", method: "PROPFIND", target: "/user", code: http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(c.method, c.target, nil))
+			if w.Code != c.code {
+				t.Fatalf("状态码为 %d, 期望 %d", w.Code, c.code)
+			}
+			if c.want != "" && w.Body.String() != c.want {
+				t.Fatalf("输出 %q, 期望 %q", w.Body.String(), c.want)
+			}
+		})
+	}
+}
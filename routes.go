@@ -0,0 +1,67 @@
+package thinko
+
+import (
+	"reflect"
+	"runtime"
+)
+
+// RouteInfo 路由信息
+type RouteInfo struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Name        string   `json:"name,omitempty"`
+	Host        string   `json:"host,omitempty"`
+	Headers     []string `json:"headers,omitempty"`
+	Handler     string   `json:"handler"`
+	Middlewares []string `json:"middlewares"`
+}
+
+// Routes 获取已注册的路由表, 中间件按执行顺序排列, 包含全局中间件
+func (engine *Engine) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(engine.routes))
+	for _, rt := range engine.routes {
+		middlewares := make([]string, 0, len(engine.middlewares)+len(rt.middlewares))
+		for _, middleware := range engine.middlewares {
+			middlewares = append(middlewares, funcName(middleware))
+		}
+		for _, middleware := range rt.middlewares {
+			middlewares = append(middlewares, funcName(middleware))
+		}
+		var headers []string
+		for _, h := range rt.headers {
+			headers = append(headers, h.key+": "+h.value)
+		}
+		routes = append(routes, RouteInfo{
+			Method:      rt.method,
+			Path:        rt.path,
+			Name:        rt.name,
+			Host:        rt.host,
+			Headers:     headers,
+			Handler:     rt.handlerName,
+			Middlewares: middlewares,
+		})
+	}
+	return routes
+}
+
+// registerRoutes 注册路由表调试路由
+func (engine *Engine) registerRoutes() {
+	if engine.RoutesPath == "" {
+		return
+	}
+	engine.GET(engine.RoutesPath, func(ctx *Context) {
+		ctx.Success(engine.Routes())
+	}).hide()
+}
+
+// funcName 获取函数名称
+func funcName(fn any) string {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func || value.IsNil() {
+		return ""
+	}
+	if f := runtime.FuncForPC(value.Pointer()); f != nil {
+		return f.Name()
+	}
+	return ""
+}
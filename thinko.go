@@ -2,16 +2,26 @@ package thinko
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/watsonhaw5566/thinko/config"
+	"github.com/watsonhaw5566/thinko/log"
+	"github.com/watsonhaw5566/thinko/trace"
 	"github.com/watsonhaw5566/thinko/util"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -27,18 +37,74 @@ const StartText = `
 // Engine 定义引擎结构体
 type Engine struct {
 	routerGroup
-	pool sync.Pool
+	pool         sync.Pool
+	trees        map[string]*node
+	mounts       *node
+	routes       []*route
+	namedRoutes  map[string]*route
+	noRoute      []HandlerFunc
+	noMethod     []HandlerFunc
+	panicHandler func(ctx *Context, recovered any)
+	maxParams    int
+	addr         atomic.Pointer[net.Addr]
+
+	state         atomic.Int32
+	startHooks    []func(ctx context.Context) error
+	shutdownHooks []func(ctx context.Context) error
+	healthChecks  map[string]func(ctx context.Context) error
+	metrics       *metrics
+
+	prepareOnce      sync.Once
+	prepareErr       error
+	compiled         bool
+	globalHandlers   []HandlerFunc
+	noRouteHandlers  []HandlerFunc
+	noMethodHandlers []HandlerFunc
+	optionsHandlers  []HandlerFunc
+
+	HandleMethodNotAllowed bool // 路径存在但请求方法未注册时返回 405 和 Allow 响应头,默认开启
+	HandleOPTIONS          bool // 未注册 OPTIONS 路由时自动响应 OPTIONS 请求,默认开启
+	HandleHEAD             bool // 未注册 HEAD 路由时使用 GET 路由响应 HEAD 请求,默认开启
+	RedirectTrailingSlash  bool // 路由不存在但去掉或加上末尾的 / 后存在时重定向,默认开启
+	RedirectFixedPath      bool // 路由不存在但清理多余的 / 和 .. 后存在时重定向,默认开启
+	CaseInsensitive        bool // 修正路径时忽略大小写查找路由并重定向到注册的路由,需开启 RedirectFixedPath,默认关闭
+
+	RoutesPath  string      // 路由表调试地址,为空则不提供,如 /debug/routes
+	OpenAPIPath string      // 接口文档地址,为空则不提供接口文档,默认 /api.json
+	SwaggerPath string      // Swagger-UI 页面地址,为空则不提供,如 /swagger, 页面资源已内置, 不依赖外部网络
+	OpenAPIInfo OpenAPIInfo // 接口文档基本信息
+
+	MetricsPath   string // Prometheus 监控指标地址,为空则不统计,如 /metrics
+	LivenessPath  string // 存活检查地址,调用 Health 后生效,默认 /healthz
+	ReadinessPath string // 就绪检查地址,调用 Health 后生效,默认 /readyz
 }
 
 // New 初始化 thinko 引擎
 func New() *Engine {
 	engine := &Engine{
 		routerGroup: routerGroup{
-			basePath:           "/",
-			handlerFuncMap:     make(map[string]map[string]HandlerFunc),
-			middlewaresFuncMap: make(map[string]map[string][]MiddlewareFunc),
+			basePath: "/",
+		},
+		trees:                  make(map[string]*node),
+		mounts:                 &node{},
+		namedRoutes:            make(map[string]*route),
+		HandleMethodNotAllowed: true,
+		HandleOPTIONS:          true,
+		HandleHEAD:             true,
+		RedirectTrailingSlash:  true,
+		RedirectFixedPath:      true,
+		OpenAPIPath:            "/api.json",
+		LivenessPath:           "/healthz",
+		ReadinessPath:          "/readyz",
+		OpenAPIInfo: OpenAPIInfo{
+			Title:   "Thinko",
+			Version: "1.0.0",
 		},
 	}
+	engine.routerGroup.engine = engine
+	engine.noRoute = []HandlerFunc{defaultNoRoute}
+	engine.noMethod = []HandlerFunc{defaultNoMethod}
+	engine.panicHandler = defaultPanicHandler
 	engine.pool.New = func() any {
 		return engine.allocateContext()
 	}
@@ -47,103 +113,617 @@ func New() *Engine {
 
 func (engine *Engine) allocateContext() any {
 	return &Context{
-		engine:   engine,
-		index:    -1,
-		handlers: make([]HandlerFunc, 0),
+		engine: engine,
+		index:  -1,
+		params: make(Params, 0, engine.maxParams),
 	}
 }
 
-// methodHandle 执行handler和中间件
-func (group *routerGroup) methodHandler(name string, method string, h HandlerFunc, ctx *Context) {
-	if group.middlewares != nil {
-		for i := len(group.middlewares) - 1; i >= 0; i-- {
-			ctx.handlers = append(ctx.handlers, group.middlewares[i]())
-		}
+// NoRoute 自定义路由不存在时的处理函数, 会经过全局中间件
+func (engine *Engine) NoRoute(handlers ...HandlerFunc) {
+	engine.noRoute = handlers
+	engine.recompile()
+}
+
+// NoMethod 自定义请求方法不允许时的处理函数, 会经过全局中间件, 需开启 HandleMethodNotAllowed
+func (engine *Engine) NoMethod(handlers ...HandlerFunc) {
+	engine.noMethod = handlers
+	engine.recompile()
+}
+
+// OnPanic 自定义非 Exception 异常的处理函数, 调用前已记录异常堆栈
+func (engine *Engine) OnPanic(handler func(ctx *Context, recovered any)) {
+	engine.panicHandler = handler
+}
+
+// compile 生成所有路由的处理链, 中间件函数只在这里调用一次, 请求时直接复用处理链
+func (engine *Engine) compile() {
+	globalHandlers := make([]HandlerFunc, 0, len(engine.middlewares))
+	for _, middleware := range engine.middlewares {
+		globalHandlers = append(globalHandlers, middleware())
 	}
-	middlewaresFunc := group.middlewaresFuncMap[name][method]
-	if middlewaresFunc != nil {
-		for i := len(middlewaresFunc) - 1; i >= 0; i-- {
-			ctx.handlers = append(ctx.handlers, middlewaresFunc[i]())
-		}
+	for _, rt := range engine.routes {
+		rt.handlers = combineHandlers(globalHandlers, rt.middlewares, rt.handler)
+	}
+	engine.noRouteHandlers = combineHandlers(globalHandlers, nil, engine.noRoute...)
+	engine.noMethodHandlers = combineHandlers(globalHandlers, nil, engine.noMethod...)
+	engine.optionsHandlers = combineHandlers(globalHandlers, nil, handleOptions)
+	engine.globalHandlers = globalHandlers
+	engine.compiled = true
+}
+
+// recompile 服务已开始处理请求后修改了中间件或路由时重新生成处理链
+func (engine *Engine) recompile() {
+	if engine.compiled {
+		engine.compile()
 	}
-	ctx.handlers = append(ctx.handlers, h)
+}
+
+// combineHandlers 按全局中间件, 路由中间件, 处理函数的顺序合并处理链
+func combineHandlers(globalHandlers []HandlerFunc, middlewares []MiddlewareFunc, handlers ...HandlerFunc) []HandlerFunc {
+	chain := make([]HandlerFunc, 0, len(globalHandlers)+len(middlewares)+len(handlers))
+	chain = append(chain, globalHandlers...)
+	for _, middleware := range middlewares {
+		chain = append(chain, middleware())
+	}
+	return append(chain, handlers...)
+}
+
+// methodHandle 执行处理链
+func (engine *Engine) methodHandler(ctx *Context, handlers []HandlerFunc) {
+	ctx.handlers = handlers
 	ctx.Next()
 }
 
 // ServeHTTP
 func (engine *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := engine.prepare(); err != nil {
+		panic(err)
+	}
 	ctx := engine.pool.Get().(*Context)
-	ctx.Response = w
-	ctx.Request = r
-	method := r.Method
-	_, ok := engine.handlerFuncMap[r.URL.Path][method]
-	if ok || util.HasSuffix(r.URL.Path) {
-		engine.methodHandler(r.URL.Path, method, engine.handlerFuncMap[r.URL.Path][method], ctx)
+	if engine.metrics == nil && !trace.Enabled() {
+		ctx.reset(w, r)
+		engine.handleHTTPRequest(ctx)
 	} else {
-		ctx.Fail("路由不存在", FailOption{
-			StatusCode: http.StatusNotFound,
-			ErrorCode:  http.StatusNotFound,
-		})
+		engine.serveObserved(ctx, w, r)
 	}
 	engine.pool.Put(ctx)
 }
 
+// serveObserved 处理请求并记录监控指标, 开启追踪时读取上游的 traceparent 并记录本次请求
+func (engine *Engine) serveObserved(ctx *Context, w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	var span *trace.Span
+	if trace.Enabled() {
+		var spanCtx context.Context
+		spanCtx, span = trace.Start(trace.Extract(r.Context(), r.Header), r.Method, trace.SpanKindServer)
+		r = r.WithContext(spanCtx)
+	}
+	ctx.reset(w, r)
+	if engine.metrics != nil {
+		engine.metrics.inFlight.Add(1)
+	}
+	engine.handleHTTPRequest(ctx)
+	status := ctx.Response.Status()
+	if engine.metrics != nil {
+		engine.metrics.inFlight.Add(-1)
+		engine.metrics.observe(r.Method, ctx.fullPath, status, time.Since(start))
+	}
+	if span != nil {
+		if ctx.fullPath != "" {
+			span.SetName(r.Method + " " + ctx.fullPath)
+			span.SetAttribute("http.route", ctx.fullPath)
+		}
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.target", r.URL.RequestURI())
+		span.SetAttribute("http.status_code", status)
+		span.SetAttribute("http.response_size", ctx.Response.Size())
+		if status >= http.StatusInternalServerError {
+			span.RecordError(errors.New(http.StatusText(status)))
+		}
+		span.End()
+	}
+}
+
+// handleHTTPRequest 匹配路由并执行,处理 HEAD OPTIONS 和 405 的情况
+func (engine *Engine) handleHTTPRequest(ctx *Context) {
+	defer ctx.finish()
+	defer engine.recovery(ctx)
+	method := ctx.Request.Method
+	urlPath := ctx.Request.URL.Path
+	if rt := engine.lookup(method, urlPath, ctx.Request, &ctx.params); rt != nil {
+		ctx.fullPath = rt.path
+		engine.methodHandler(ctx, rt.handlers)
+		return
+	}
+	// 未单独注册的请求方法如 PROPFIND 同样转发给 Mount 挂载的处理器
+	if rt := engine.mounts.getValue(urlPath, ctx.Request, &ctx.params); rt != nil {
+		ctx.fullPath = rt.path
+		engine.methodHandler(ctx, rt.handlers)
+		return
+	}
+	// 自动响应的重定向和 OPTIONS 同样经过全局中间件, 如跨域、访问日志
+	if method != http.MethodConnect && urlPath != "/" {
+		if target := engine.fixedPath(ctx); target != "" {
+			engine.methodHandler(ctx, combineHandlers(engine.globalHandlers, nil, redirectHandler(target)))
+			return
+		}
+	}
+	if method == http.MethodOptions && engine.HandleOPTIONS {
+		if allow := engine.allowed(urlPath, ctx.Request); allow != "" {
+			ctx.Response.Header().Set("Allow", allow)
+			engine.methodHandler(ctx, engine.optionsHandlers)
+			return
+		}
+	}
+	if engine.HandleMethodNotAllowed {
+		if allow := engine.allowed(urlPath, ctx.Request); allow != "" {
+			ctx.Response.Header().Set("Allow", allow)
+			engine.methodHandler(ctx, engine.noMethodHandlers)
+			return
+		}
+	}
+	// 静态资源由全局中间件中的文件服务处理
+	engine.methodHandler(ctx, engine.noRouteHandlers)
+}
+
+// URL 根据路由名称生成地址, params 为键值对, 路由参数之外的键值对作为查询参数
+// engine.URL("user.show", "id", 1, "tab", "posts") => /user/1?tab=posts
+func (engine *Engine) URL(name string, params ...any) string {
+	rt, ok := engine.namedRoutes[name]
+	if !ok {
+		panic(routeException(fmt.Sprintf("路由名称不存在 [%s]", name)))
+	}
+	if len(params)%2 != 0 {
+		panic(routeException(fmt.Sprintf("路由参数必须成对出现 [%s]", name)))
+	}
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[fmt.Sprint(params[i])] = fmt.Sprint(params[i+1])
+	}
+	segments := strings.Split(rt.path, "/")
+	for i, segment := range segments {
+		key, _, ok := parseParam(segment)
+		if !ok && strings.HasPrefix(segment, "*") {
+			key, ok = segment[1:], true
+		}
+		if !ok {
+			continue
+		}
+		value, ok := values[key]
+		if !ok {
+			panic(routeException(fmt.Sprintf("缺少路由参数 [%s][%s]", name, key)))
+		}
+		if segment[0] == '*' {
+			// 通配参数按 / 分段转义, 保留层级
+			parts := strings.Split(strings.TrimPrefix(value, "/"), "/")
+			for j, part := range parts {
+				parts[j] = url.PathEscape(part)
+			}
+			segments[i] = strings.Join(parts, "/")
+		} else {
+			segments[i] = url.PathEscape(value)
+		}
+		delete(values, key)
+	}
+	query := url.Values{}
+	for key, value := range values {
+		query.Set(key, value)
+	}
+	urlPath := strings.Join(segments, "/")
+	if len(query) > 0 {
+		urlPath += "?" + query.Encode()
+	}
+	return urlPath
+}
+
+// lookup 查找路由, 开启 HandleHEAD 时 HEAD 请求可匹配 GET 路由
+func (engine *Engine) lookup(method string, urlPath string, req *http.Request, params *Params) *route {
+	rt := engine.getRoute(method, urlPath, req, params)
+	if rt == nil && method == http.MethodHead && engine.HandleHEAD {
+		rt = engine.getRoute(http.MethodGet, urlPath, req, params)
+	}
+	return rt
+}
+
+// fixedPath 按 RedirectTrailingSlash RedirectFixedPath CaseInsensitive 查找需要重定向的规范路径, 不需要重定向时为空
+func (engine *Engine) fixedPath(ctx *Context) string {
+	method := ctx.Request.Method
+	urlPath := ctx.Request.URL.Path
+	target := ""
+	if engine.RedirectTrailingSlash {
+		fixedPath := urlPath + "/"
+		if strings.HasSuffix(urlPath, "/") {
+			fixedPath = urlPath[:len(urlPath)-1]
+		}
+		if engine.lookup(method, fixedPath, ctx.Request, &Params{}) != nil {
+			target = fixedPath
+		}
+	}
+	if target == "" && engine.RedirectFixedPath {
+		cleanPath := path.Clean("/" + urlPath)
+		if cleanPath != urlPath && engine.lookup(method, cleanPath, ctx.Request, &Params{}) != nil {
+			target = cleanPath
+		} else if engine.CaseInsensitive {
+			target = engine.findCaseInsensitivePath(method, cleanPath, ctx.Request)
+		}
+	}
+	if target == urlPath {
+		return ""
+	}
+	return target
+}
+
+// redirectHandler 重定向到规范路径, GET 请求使用 301, 其他请求使用 308 保留请求方法和请求体
+func redirectHandler(target string) HandlerFunc {
+	return func(ctx *Context) {
+		code := http.StatusMovedPermanently
+		if ctx.Request.Method != http.MethodGet {
+			code = http.StatusPermanentRedirect
+		}
+		u := *ctx.Request.URL
+		u.Path = target
+		u.RawPath = ""
+		http.Redirect(ctx.Response, ctx.Request, u.RequestURI(), code)
+	}
+}
+
+// handleOptions 自动响应 OPTIONS 请求, Allow 响应头在执行处理链前已设置
+func handleOptions(ctx *Context) {
+	ctx.Response.WriteHeader(http.StatusNoContent)
+}
+
+// findCaseInsensitivePath 忽略大小写查找路由, 返回按注册路由修正大小写后的路径
+func (engine *Engine) findCaseInsensitivePath(method string, urlPath string, req *http.Request) string {
+	methods := []string{method}
+	if method == http.MethodHead && engine.HandleHEAD {
+		methods = append(methods, http.MethodGet)
+	}
+	for _, m := range methods {
+		if root, ok := engine.trees[m]; ok {
+			if fixedPath, found := root.findCaseInsensitivePath(urlPath, req, &Params{}); found {
+				return fixedPath
+			}
+		}
+	}
+	return ""
+}
+
+// getRoute 查找请求方法和路径对应的路由
+func (engine *Engine) getRoute(method string, urlPath string, req *http.Request, params *Params) *route {
+	root, ok := engine.trees[method]
+	if !ok {
+		return nil
+	}
+	return root.getValue(urlPath, req, params)
+}
+
+// allowed 获取路径已注册的请求方法, 用于 Allow 响应头
+func (engine *Engine) allowed(urlPath string, req *http.Request) string {
+	var methods []string
+	for method, root := range engine.trees {
+		if root.getValue(urlPath, req, &Params{}) != nil {
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) == 0 {
+		return ""
+	}
+	if engine.HandleHEAD && util.StringInSlice(http.MethodGet, methods) && !util.StringInSlice(http.MethodHead, methods) {
+		methods = append(methods, http.MethodHead)
+	}
+	if engine.HandleOPTIONS && !util.StringInSlice(http.MethodOptions, methods) {
+		methods = append(methods, http.MethodOptions)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
 // Run 独立使用启动, 在调用前自行绑定路由和控制器
+// 监听配置中的 Address, 以 unix: 开头时监听 Unix 套接字, 配置了证书时启用 HTTPS
 func (engine *Engine) Run() {
-	// 全局异常捕获中间件
-	engine.Use(recoveryMiddleware)
-	// 静态文件服务
-	engine.Use(fileServerMiddleware)
+	listener, err := engine.listen()
+	if err != nil {
+		color.Red(fmt.Sprintf("[Thinko] 服务启动失败: %s", err.Error()))
+		return
+	}
+	engine.run(listener, config.Config.Server.CertFile, config.Config.Server.KeyFile)
+}
+
+// RunTLS 启动 HTTPS 服务, 客户端支持时自动使用 HTTP/2
+func (engine *Engine) RunTLS(certFile string, keyFile string) {
+	listener, err := net.Listen("tcp", config.Config.Server.Address)
+	if err != nil {
+		color.Red(fmt.Sprintf("[Thinko] 服务启动失败: %s", err.Error()))
+		return
+	}
+	engine.run(listener, certFile, keyFile)
+}
 
-	// http服务
-	cmd := &http.Server{
-		Addr:    config.Config.Server.Address,
-		Handler: engine,
+// RunUnix 在 Unix 套接字上启动服务, 已存在的套接字文件会被删除
+func (engine *Engine) RunUnix(file string) {
+	listener, err := listenUnix(file)
+	if err != nil {
+		color.Red(fmt.Sprintf("[Thinko] 服务启动失败: %s", err.Error()))
+		return
 	}
+	engine.run(listener, "", "")
+}
+
+// RunListener 在指定的监听器上启动服务
+func (engine *Engine) RunListener(listener net.Listener) {
+	engine.run(listener, "", "")
+}
 
-	// 异步启动服务
+// Start 按配置监听地址并在后台启动服务, 监听失败时立即返回错误, ctx 取消后关闭服务
+func (engine *Engine) Start(ctx context.Context) error {
+	listener, err := engine.listen()
+	if err != nil {
+		return err
+	}
+	server, errChan, err := engine.startServer(ctx, listener, config.Config.Server.CertFile, config.Config.Server.KeyFile)
+	if err != nil {
+		return err
+	}
 	go func() {
-		if err := cmd.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			color.Red("[Thinko] 服务启动失败")
-			return
+		if err := engine.waitServer(ctx, server, errChan); err != nil {
+			log.Log().Error(fmt.Sprintf("[Thinko] 服务异常退出: %s", err.Error()))
 		}
 	}()
+	return nil
+}
 
-	// 检查端口的通道
-	done := make(chan bool, 1)
-	// 检查端口是否已经打开
-	go func() {
-		for {
-			conn, err := net.Dial("tcp", config.Config.Server.Address)
-			if err == nil {
-				conn.Close()
-				fmt.Print(strings.TrimPrefix(StartText, "\n"))
-				color.Blue(fmt.Sprintf("[Thinko] 服务地址: http://127.0.0.1%s", config.Config.Server.Address))
-				color.Blue(fmt.Sprintf("[Thinko] 接口文档地址: http://127.0.0.1%s/api.json", config.Config.Server.Address))
-				done <- true
-				break
+// Serve 按配置监听地址并启动服务, 阻塞直到 ctx 取消后服务关闭, 正常关闭时返回 nil
+func (engine *Engine) Serve(ctx context.Context) error {
+	listener, err := engine.listen()
+	if err != nil {
+		return err
+	}
+	server, errChan, err := engine.startServer(ctx, listener, config.Config.Server.CertFile, config.Config.Server.KeyFile)
+	if err != nil {
+		return err
+	}
+	return engine.waitServer(ctx, server, errChan)
+}
+
+// ServeListener 在指定的监听器上启动服务, 阻塞直到 ctx 取消后服务关闭, 正常关闭时返回 nil
+func (engine *Engine) ServeListener(ctx context.Context, listener net.Listener) error {
+	server, errChan, err := engine.startServer(ctx, listener, "", "")
+	if err != nil {
+		return err
+	}
+	return engine.waitServer(ctx, server, errChan)
+}
+
+// Addr 服务实际监听的地址, 服务启动前为 nil
+func (engine *Engine) Addr() net.Addr {
+	if addr := engine.addr.Load(); addr != nil {
+		return *addr
+	}
+	return nil
+}
+
+// listen 监听配置中的地址, 以 unix: 开头时监听 Unix 套接字
+func (engine *Engine) listen() (net.Listener, error) {
+	address := config.Config.Server.Address
+	if strings.HasPrefix(address, "unix:") {
+		return listenUnix(strings.TrimPrefix(address, "unix:"))
+	}
+	return net.Listen("tcp", address)
+}
+
+// listenUnix 监听 Unix 套接字, 已存在的套接字文件会被删除, 路径是其他文件时返回错误
+func listenUnix(file string) (net.Listener, error) {
+	info, err := os.Lstat(file)
+	if err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s 已存在且不是 Unix 套接字", file)
+		}
+		if err = os.Remove(file); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return net.Listen("unix", file)
+}
+
+// prepare 启动或处理第一个请求时注册静态文件服务、接口文档、路由表和监控指标, 然后生成处理链
+// 通过 httptest 或自定义的 http.Server 使用 Engine 时同样生效
+// 注册失败时, 如自定义的路由与接口文档地址重复, 返回错误, 之后每次调用都返回同一个错误
+func (engine *Engine) prepare() error {
+	engine.prepareOnce.Do(func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				if exception, ok := recovered.(Exception); ok {
+					engine.prepareErr = errors.New(exception.Message)
+				} else {
+					engine.prepareErr = fmt.Errorf("%v", recovered)
+				}
 			}
-			time.Sleep(100 * time.Millisecond)
+		}()
+		// 静态文件服务, 放在全局中间件的最前面
+		engine.middlewares = append([]MiddlewareFunc{fileServerMiddleware}, engine.middlewares...)
+		// 接口文档
+		engine.registerOpenAPI()
+		// 路由表
+		engine.registerRoutes()
+		// 监控指标
+		engine.registerMetrics()
+		engine.compile()
+	})
+	return engine.prepareErr
+}
+
+// newServer 根据配置创建 http 服务
+func (engine *Engine) newServer() (*http.Server, error) {
+	conf := config.Config.Server
+	server := &http.Server{
+		Handler:        engine,
+		ReadTimeout:    conf.ReadTimeout,
+		WriteTimeout:   conf.WriteTimeout,
+		IdleTimeout:    conf.IdleTimeout,
+		MaxHeaderBytes: conf.MaxHeaderBytes,
+	}
+	if conf.H2C {
+		h2s := &http2.Server{IdleTimeout: conf.IdleTimeout}
+		if err := http2.ConfigureServer(server, h2s); err != nil {
+			return nil, err
+		}
+		server.Handler = h2c.NewHandler(engine, h2s)
+	}
+	return server, nil
+}
+
+// OnStart 注册服务启动钩子, 在开始监听后、处理请求前按注册顺序执行, 返回错误时服务不会启动
+// 启动失败时会按相反顺序执行全部关闭钩子, 释放之前的启动钩子打开的资源
+func (engine *Engine) OnStart(hook func(ctx context.Context) error) {
+	engine.startHooks = append(engine.startHooks, hook)
+}
+
+// OnShutdown 注册服务关闭钩子, 在请求处理完成后按注册的相反顺序执行
+// 如关闭数据库连接池: engine.OnShutdown(func(ctx context.Context) error { return thinko.CloseDb() })
+func (engine *Engine) OnShutdown(hook func(ctx context.Context) error) {
+	engine.shutdownHooks = append(engine.shutdownHooks, hook)
+}
+
+// 服务状态, 通过 Start Serve Run 等方法启动时使用, 挂载到自定义的 http.Server 时始终为 stateIdle
+const (
+	stateIdle     int32 = iota // 未通过内置方法启动
+	stateStarting              // 正在执行启动钩子
+	stateRunning               // 正在运行
+	stateStopping              // 正在关闭或已关闭
+)
+
+// Ready 服务是否就绪, 通过 Start Serve Run 等方法启动时启动完成后为 true, 开始关闭时立即变为 false
+// 未通过这些方法启动, 如挂载到自定义的 http.Server 或 httptest.NewServer 时视为就绪
+func (engine *Engine) Ready() bool {
+	state := engine.state.Load()
+	return state == stateIdle || state == stateRunning
+}
+
+// startServer 执行启动钩子后在监听器上异步启动服务, 同时配置证书和私钥时启用 HTTPS
+func (engine *Engine) startServer(ctx context.Context, listener net.Listener, certFile string, keyFile string) (*http.Server, chan error, error) {
+	if err := engine.prepare(); err != nil {
+		listener.Close()
+		return nil, nil, err
+	}
+	server, err := engine.newServer()
+	if err != nil {
+		listener.Close()
+		return nil, nil, err
+	}
+	engine.state.Store(stateStarting)
+	for _, hook := range engine.startHooks {
+		if err := hook(ctx); err != nil {
+			engine.state.Store(stateStopping)
+			listener.Close()
+			return nil, nil, errors.Join(err, engine.shutdown())
+		}
+	}
+	addr := listener.Addr()
+	engine.addr.Store(&addr)
+	errChan := make(chan error, 1)
+	go func() {
+		if certFile != "" && keyFile != "" {
+			errChan <- server.ServeTLS(listener, certFile, keyFile)
+		} else {
+			errChan <- server.Serve(listener)
 		}
 	}()
+	engine.state.Store(stateRunning)
+	return server, errChan, nil
+}
 
-	// 监听超时
+// waitServer 等待服务结束, ctx 取消后先标记为未就绪, 等待 ShutdownDelay 后关闭服务
+// 关闭时最多等待 ShutdownTimeout 让正在处理的请求完成, 最后执行关闭钩子
+func (engine *Engine) waitServer(ctx context.Context, server *http.Server, errChan chan error) error {
+	conf := config.Config.Server
+	var err error
 	select {
-	case <-done:
-	case <-time.After(5 * time.Second):
-		color.Red("[Thinko] 服务启动超时,请手动重启")
-		return
+	case err = <-errChan:
+		engine.state.Store(stateStopping)
+	case <-ctx.Done():
+		engine.state.Store(stateStopping)
+		// 等待负载均衡感知到未就绪并摘除流量
+		time.Sleep(conf.ShutdownDelay)
 	}
+	if err == nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
+		err = server.Shutdown(shutdownCtx)
+		cancel()
+	}
+	return errors.Join(err, engine.shutdown())
+}
 
-	// 主 goroutine 继续执行
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// shutdown 按注册的相反顺序执行关闭钩子, 最多等待 ShutdownTimeout
+func (engine *Engine) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
 	defer cancel()
-	if err := cmd.Shutdown(ctx); err != nil {
-		color.Red("[Thinko] 服务未能正常关闭")
+	var err error
+	for i := len(engine.shutdownHooks) - 1; i >= 0; i-- {
+		if hookErr := engine.shutdownHooks[i](ctx); hookErr != nil {
+			err = errors.Join(err, hookErr)
+		}
+	}
+	return err
+}
+
+// shutdownTimeout 关闭服务的超时时间, 未配置时为 5s
+func shutdownTimeout() time.Duration {
+	if timeout := config.Config.Server.ShutdownTimeout; timeout > 0 {
+		return timeout
+	}
+	return 5 * time.Second
+}
+
+// run 启动服务并输出启动信息, 收到 SIGINT SIGTERM SIGQUIT 信号后关闭服务, 关闭期间再次收到信号时立即退出
+func (engine *Engine) run(listener net.Listener, certFile string, keyFile string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
+	go func() {
+		// 第一次收到信号后恢复默认处理, 再次收到信号时进程直接退出
+		<-ctx.Done()
+		stop()
+	}()
+	server, errChan, err := engine.startServer(ctx, listener, certFile, keyFile)
+	if err != nil {
+		color.Red(fmt.Sprintf("[Thinko] 服务启动失败: %s", err.Error()))
+		return
+	}
+
+	address := serverAddress(listener, certFile != "" && keyFile != "")
+	fmt.Print(strings.TrimPrefix(StartText, "\n"))
+	color.Blue(fmt.Sprintf("[Thinko] 服务地址: %s", address))
+	if engine.OpenAPIPath != "" {
+		color.Blue(fmt.Sprintf("[Thinko] 接口文档地址: %s%s", address, engine.OpenAPIPath))
+	}
+	if engine.RoutesPath != "" {
+		color.Blue(fmt.Sprintf("[Thinko] 路由表地址: %s%s", address, engine.RoutesPath))
+	}
+	if engine.SwaggerPath != "" {
+		color.Blue(fmt.Sprintf("[Thinko] Swagger 地址: %s%s", address, engine.SwaggerPath))
+	}
+
+	if err := engine.waitServer(ctx, server, errChan); err != nil {
+		if ctx.Err() != nil {
+			color.Red("[Thinko] 服务未能正常关闭")
+		} else {
+			color.Red(fmt.Sprintf("[Thinko] 服务异常退出: %s", err.Error()))
+		}
 		return
 	}
 	color.Green("[Thinko] 服务正常关闭")
 }
+
+// serverAddress 服务的访问地址, 用于启动时输出
+func serverAddress(listener net.Listener, tls bool) string {
+	addr, ok := listener.Addr().(*net.TCPAddr)
+	if !ok {
+		return listener.Addr().Network() + ":" + listener.Addr().String()
+	}
+	if tls {
+		return fmt.Sprintf("https://127.0.0.1:%d", addr.Port)
+	}
+	return fmt.Sprintf("http://127.0.0.1:%d", addr.Port)
+}
@@ -0,0 +1,206 @@
+package thinko
+
+import (
+	"context"
+	"errors"
+	tkHttp "github.com/watsonhaw5566/thinko/http"
+	"github.com/watsonhaw5566/thinko/trace"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// serveTest 在随机端口上启动服务, 返回服务地址和等待服务结束的函数
+func serveTest(t *testing.T, ctx context.Context, engine *Engine) (string, func() error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- engine.ServeListener(ctx, listener)
+	}()
+	address := "http://" + listener.Addr().String()
+	deadline := time.Now().Add(time.Second)
+	// 未启动时 Ready 同样为 true, 需等到记录了监听地址
+	for engine.Addr() == nil || !engine.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatal("服务未在 1s 内就绪")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return address, func() error {
+		select {
+		case err := <-errChan:
+			return err
+		case <-time.After(10 * time.Second):
+			t.Fatal("服务未在 10s 内关闭")
+			return nil
+		}
+	}
+}
+
+func TestServeListenerHooks(t *testing.T) {
+	var (
+		mutex  sync.Mutex
+		events []string
+	)
+	record := func(event string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			events = append(events, event)
+			return nil
+		}
+	}
+
+	engine := New()
+	engine.GET("/ping", func(ctx *Context) {
+		record("request")(ctx.Request.Context())
+		ctx.Response.Write([]byte("pong"))
+	})
+	engine.OnStart(record("start1"))
+	engine.OnStart(record("start2"))
+	engine.OnShutdown(record("shutdown1"))
+	engine.OnShutdown(record("shutdown2"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	address, wait := serveTest(t, ctx, engine)
+	if addr := engine.Addr(); addr == nil || "http://"+addr.String() != address {
+		t.Fatalf("Addr 为 %v, 期望 %s", addr, address)
+	}
+	res, err := http.Get(address + "/ping")
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "pong" {
+		t.Fatalf("响应为 %q", body)
+	}
+
+	cancel()
+	if err := wait(); err != nil {
+		t.Fatalf("正常关闭应返回 nil, 实际为 %v", err)
+	}
+	if engine.Ready() {
+		t.Fatal("关闭后应为未就绪")
+	}
+	want := []string{"start1", "start2", "request", "shutdown2", "shutdown1"}
+	if !slices.Equal(events, want) {
+		t.Fatalf("钩子执行顺序为 %v, 期望 %v", events, want)
+	}
+}
+
+func TestServeListenerStartError(t *testing.T) {
+	var events []string
+	record := func(event string, err error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			events = append(events, event)
+			return err
+		}
+	}
+	startErr := errors.New("连接数据库失败")
+	engine := New()
+	engine.OnStart(record("start1", nil))
+	engine.OnStart(record("start2", startErr))
+	engine.OnStart(record("start3", nil))
+	engine.OnShutdown(record("shutdown1", nil))
+	engine.OnShutdown(record("shutdown2", nil))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	if err := engine.ServeListener(context.Background(), listener); !errors.Is(err, startErr) {
+		t.Fatalf("应返回启动钩子的错误, 实际为 %v", err)
+	}
+	want := []string{"start1", "start2", "shutdown2", "shutdown1"}
+	if !slices.Equal(events, want) {
+		t.Fatalf("钩子执行顺序为 %v, 期望 %v", events, want)
+	}
+	if engine.Ready() {
+		t.Fatal("启动失败后应为未就绪")
+	}
+}
+
+func TestServeListenerPrepareError(t *testing.T) {
+	engine := New()
+	engine.GET("/api.json", echo("custom"))
+	started := false
+	engine.OnStart(func(ctx context.Context) error {
+		started = true
+		return nil
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	err = engine.ServeListener(context.Background(), listener)
+	if err == nil || !strings.Contains(err.Error(), "路由重复") {
+		t.Fatalf("路由与接口文档地址重复时应返回错误, 实际为 %v", err)
+	}
+	if started {
+		t.Fatal("注册失败时不应执行启动钩子")
+	}
+	if _, err := listener.Accept(); err == nil {
+		t.Fatal("注册失败时应关闭监听器")
+	}
+	if err2 := engine.ServeListener(context.Background(), listener); err2 == nil || err2.Error() != err.Error() {
+		t.Fatalf("再次启动应返回同一个错误, 实际为 %v", err2)
+	}
+}
+
+func TestTraceParentPropagation(t *testing.T) {
+	exporter := trace.NewMemoryExporter()
+	trace.SetExporter(exporter)
+	defer trace.SetExporter(nil)
+
+	var downstream string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = r.Header.Get(trace.TraceParentHeader)
+	}))
+	defer server.Close()
+
+	engine := New()
+	engine.GET("/user/:id", func(ctx *Context) {
+		if _, err := tkHttp.NewClient().WithContext(ctx.Request.Context()).GET(server.URL); err != nil {
+			t.Errorf("请求下游服务失败: %v", err)
+		}
+	})
+
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	r := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+	r.Header.Set(trace.TraceParentHeader, "00-"+traceID+"-"+spanID+"-01")
+	engine.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("期望 2 个追踪记录, 实际为 %d", len(spans))
+	}
+	// 客户端请求先结束, 先导出
+	client, serverSpan := spans[0], spans[1]
+	if serverSpan.Kind != trace.SpanKindServer || serverSpan.Name != "GET /user/:id" {
+		t.Fatalf("服务端追踪不正确: %s %s", serverSpan.Kind, serverSpan.Name)
+	}
+	if serverSpan.TraceID != traceID || serverSpan.ParentSpanID != spanID {
+		t.Fatalf("服务端追踪应延续上游的 traceparent, 实际为 %s %s", serverSpan.TraceID, serverSpan.ParentSpanID)
+	}
+	if client.Kind != trace.SpanKindClient || client.TraceID != traceID || client.ParentSpanID != serverSpan.SpanID {
+		t.Fatalf("客户端追踪应以服务端追踪为父级: %s %s %s", client.Kind, client.TraceID, client.ParentSpanID)
+	}
+	if want := client.SpanContext().TraceParent(); downstream != want {
+		t.Fatalf("下游收到的 traceparent 为 %q, 期望 %q", downstream, want)
+	}
+}
@@ -0,0 +1,70 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// MemoryExporter 内存导出器, 用于测试
+type MemoryExporter struct {
+	mutex sync.Mutex
+	spans []*Span
+}
+
+// NewMemoryExporter 创建内存导出器
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+// ExportSpan 保存追踪记录
+func (e *MemoryExporter) ExportSpan(span *Span) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans 获取已结束的追踪记录, 按结束顺序排列
+func (e *MemoryExporter) Spans() []*Span {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	spans := make([]*Span, len(e.spans))
+	copy(spans, e.spans)
+	return spans
+}
+
+// Reset 清空追踪记录
+func (e *MemoryExporter) Reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = nil
+}
+
+// StdoutExporter 标准输出导出器, 每条追踪记录输出一行 JSON
+type StdoutExporter struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+// NewStdoutExporter 创建标准输出导出器, writer 为空时输出到 os.Stdout
+func NewStdoutExporter(writer ...io.Writer) *StdoutExporter {
+	e := &StdoutExporter{writer: os.Stdout}
+	if len(writer) > 0 && writer[0] != nil {
+		e.writer = writer[0]
+	}
+	return e
+}
+
+// ExportSpan 输出追踪记录
+func (e *StdoutExporter) ExportSpan(span *Span) {
+	span.mutex.Lock()
+	data, err := json.Marshal(span)
+	span.mutex.Unlock()
+	if err != nil {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.writer.Write(append(data, '\n'))
+}
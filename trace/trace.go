@@ -0,0 +1,258 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceParentHeader W3C Trace Context 请求头
+const TraceParentHeader = "traceparent"
+
+// SpanKind 追踪类型
+type SpanKind string
+
+const (
+	SpanKindServer   SpanKind = "server"   // 处理收到的请求
+	SpanKindClient   SpanKind = "client"   // 调用外部服务, 如数据库、Redis、HTTP
+	SpanKindInternal SpanKind = "internal" // 内部操作
+)
+
+// SpanContext 追踪上下文, 跨服务传递
+type SpanContext struct {
+	TraceID string // 32 位十六进制
+	SpanID  string // 16 位十六进制
+	Sampled bool   // 是否采样
+}
+
+// IsValid 追踪上下文是否有效
+func (sc SpanContext) IsValid() bool {
+	return isHex(sc.TraceID, 32) && isHex(sc.SpanID, 16)
+}
+
+// TraceParent 生成 traceparent 请求头的值
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID + "-" + sc.SpanID + "-" + flags
+}
+
+// ParseTraceParent 解析 traceparent 请求头, 格式为 version-traceid-spanid-flags
+func ParseTraceParent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || !isHex(parts[0], 2) || parts[0] == "ff" || !isHex(parts[3], 2) {
+		return SpanContext{}, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	flags, _ := hex.DecodeString(parts[3])
+	sc := SpanContext{
+		TraceID: parts[1],
+		SpanID:  parts[2],
+		Sampled: flags[0]&0x01 == 0x01,
+	}
+	return sc, sc.IsValid()
+}
+
+// Span 一次操作的追踪记录
+type Span struct {
+	Name         string         `json:"name"`
+	Kind         SpanKind       `json:"kind"`
+	TraceID      string         `json:"traceId"`
+	SpanID       string         `json:"spanId"`
+	ParentSpanID string         `json:"parentSpanId,omitempty"`
+	StartTime    time.Time      `json:"startTime"`
+	EndTime      time.Time      `json:"endTime"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Error        string         `json:"error,omitempty"`
+
+	sampled bool
+	ended   bool
+	mutex   sync.Mutex
+}
+
+// SpanContext 获取追踪上下文, 用于向下游传递
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return SpanContext{TraceID: s.TraceID, SpanID: s.SpanID, Sampled: s.sampled}
+}
+
+// SetName 修改名称, 如路由匹配后使用路由作为名称
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Name = name
+}
+
+// SetAttribute 设置属性
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]any)
+	}
+	s.Attributes[key] = value
+}
+
+// RecordError 记录错误, err 为 nil 时忽略
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Error = err.Error()
+}
+
+// End 结束并导出, 重复调用只导出一次
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = time.Now()
+	s.mutex.Unlock()
+	if exp := loadExporter(); exp != nil && s.sampled {
+		exp.ExportSpan(s)
+	}
+}
+
+// Exporter 追踪数据导出器
+type Exporter interface {
+	ExportSpan(span *Span)
+}
+
+// exporterHolder 保存导出器, atomic.Value 不能保存不同类型的值
+type exporterHolder struct {
+	exporter Exporter
+}
+
+var exporter atomic.Value
+
+// SetExporter 设置导出器, 为 nil 时关闭追踪
+func SetExporter(e Exporter) {
+	exporter.Store(exporterHolder{exporter: e})
+}
+
+// Enabled 是否开启了追踪
+func Enabled() bool {
+	return loadExporter() != nil
+}
+
+// loadExporter 获取导出器
+func loadExporter() Exporter {
+	holder, _ := exporter.Load().(exporterHolder)
+	return holder.exporter
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// Start 开始一次追踪, 父级为 ctx 中的追踪或上游传入的追踪上下文
+// 未开启追踪时返回原 ctx 和 nil, Span 的方法都可以在 nil 上调用
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if !Enabled() {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	span := &Span{
+		Name:      name,
+		Kind:      kind,
+		SpanID:    randomHex(8),
+		StartTime: time.Now(),
+		sampled:   true,
+	}
+	if parent := SpanContextFromContext(ctx); parent.IsValid() {
+		span.TraceID = parent.TraceID
+		span.ParentSpanID = parent.SpanID
+		span.sampled = parent.Sampled
+	} else {
+		span.TraceID = randomHex(16)
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext 获取 ctx 中的追踪, 不存在返回 nil
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext 获取 ctx 中的追踪上下文, 优先使用当前追踪, 其次使用上游传入的追踪上下文
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := FromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	if ctx == nil {
+		return SpanContext{}
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// Extract 从请求头中读取上游的追踪上下文并保存到 ctx
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := ParseTraceParent(header.Get(TraceParentHeader))
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Inject 将 ctx 中的追踪上下文写入请求头, 传递给下游服务
+func Inject(ctx context.Context, header http.Header) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		header.Set(TraceParentHeader, sc.TraceParent())
+	}
+}
+
+// randomHex 生成 n 字节的随机十六进制字符串
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// isHex 判断是否为指定长度且不全为 0 的小写十六进制字符串
+func isHex(value string, length int) bool {
+	if len(value) != length {
+		return false
+	}
+	zero := true
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+		if c != '0' {
+			zero = false
+		}
+	}
+	return !zero || length == 2
+}
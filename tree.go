@@ -0,0 +1,392 @@
+package thinko
+
+import (
+	"fmt"
+	"github.com/watsonhaw5566/thinko/util"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Param 路由参数
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params 路由参数列表
+type Params []Param
+
+// Get 获取路由参数,第二个返回值表示参数是否存在
+func (ps Params) Get(name string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// ByName 获取路由参数,不存在返回空字符串
+func (ps Params) ByName(name string) string {
+	value, _ := ps.Get(name)
+	return value
+}
+
+// nodeType 路由树节点类型
+type nodeType uint8
+
+const (
+	staticNode   nodeType = iota // 静态节点 /user
+	paramNode                    // 命名参数节点 /:id /{id} /{id:int}
+	catchAllNode                 // 通配节点 /*filepath
+)
+
+// route 注册的路由
+type route struct {
+	method      string
+	path        string
+	handler     HandlerFunc
+	handlerName string // 处理函数名称
+	middlewares []MiddlewareFunc
+	handlers    []HandlerFunc // 预先生成的处理链
+	name        string        // 路由名称
+	host        string        // 域名匹配规则
+	headers     []headerMatch // 请求头匹配规则
+	summary     string        // 接口摘要
+	description string        // 接口描述
+	tags        []string      // 接口分组
+	reqType     reflect.Type  // 请求参数结构体
+	resType     reflect.Type  // 响应数据结构体
+	hidden      bool          // 不出现在接口文档中
+}
+
+// node 路由树节点,每个节点对应路径中的一段
+type node struct {
+	segment       string
+	key           string
+	nType         nodeType
+	constraint    string            // 参数约束, 如 int uuid [a-z]+
+	check         func(string) bool // 参数约束校验, 为空时不限制
+	children      map[string]*node
+	paramChildren []*node // 命名参数节点, 带约束的排在前面
+	catchAll      *node
+	routes        []*route // 同一路径下按匹配条件区分的路由, 条件越具体越靠前
+}
+
+// paramConstraints 内置的参数约束
+var paramConstraints = map[string]func(string) bool{
+	"int": func(s string) bool {
+		_, err := strconv.Atoi(s)
+		return err == nil
+	},
+	"uint": func(s string) bool {
+		_, err := strconv.ParseUint(s, 10, 0)
+		return err == nil
+	},
+	"alpha": regexp.MustCompile(`^[a-zA-Z]+$`).MatchString,
+	"alnum": regexp.MustCompile(`^[a-zA-Z0-9]+$`).MatchString,
+	"uuid":  regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+}
+
+// parseParam 解析命名参数段 :id {id} {id:int} {name:[a-z]+\.png}, 不是命名参数时 ok 为 false
+func parseParam(segment string) (key string, constraint string, ok bool) {
+	if strings.HasPrefix(segment, ":") {
+		return segment[1:], "", true
+	}
+	if len(segment) < 2 || segment[0] != '{' || segment[len(segment)-1] != '}' {
+		return "", "", false
+	}
+	key, constraint, _ = strings.Cut(segment[1:len(segment)-1], ":")
+	return key, constraint, true
+}
+
+// compileConstraint 编译参数约束, 非内置约束按正则表达式整段匹配
+func compileConstraint(fullPath string, constraint string) func(string) bool {
+	if constraint == "" {
+		return nil
+	}
+	if check, ok := paramConstraints[constraint]; ok {
+		return check
+	}
+	re, err := regexp.Compile("^(?:" + constraint + ")$")
+	if err != nil {
+		panic(routeException(fmt.Sprintf("路由参数约束错误 [%s]: %s", fullPath, err.Error())))
+	}
+	return re.MatchString
+}
+
+// headerMatch 请求头匹配规则, value 为空时只要求请求头存在
+type headerMatch struct {
+	key   string
+	value string
+}
+
+// addRoute 向路由树中添加路由, fullPath 必须以 / 开头
+func (n *node) addRoute(fullPath string, r *route) {
+	current := n
+	segments := strings.Split(fullPath[1:], "/")
+	for i, segment := range segments {
+		switch {
+		case isParam(segment):
+			current = current.addParamChild(fullPath, segment)
+		case strings.HasPrefix(segment, "*"):
+			key := segment[1:]
+			if key == "" {
+				panic(routeException(fmt.Sprintf("通配参数名不能为空 [%s]", fullPath)))
+			}
+			if i != len(segments)-1 {
+				panic(routeException(fmt.Sprintf("通配参数只能位于路由末尾 [%s]", fullPath)))
+			}
+			if current.catchAll == nil {
+				current.catchAll = &node{segment: segment, key: key, nType: catchAllNode}
+			} else if current.catchAll.key != key {
+				panic(routeException(fmt.Sprintf("通配参数冲突 [%s] 与已存在的 [%s]", fullPath, current.catchAll.segment)))
+			}
+			current = current.catchAll
+		default:
+			if current.children == nil {
+				current.children = make(map[string]*node)
+			}
+			child, ok := current.children[segment]
+			if !ok {
+				child = &node{segment: segment, nType: staticNode}
+				current.children[segment] = child
+			}
+			current = child
+		}
+	}
+	for _, exist := range current.routes {
+		if exist.conditionKey() == r.conditionKey() {
+			panic(routeException(fmt.Sprintf("路由重复 [%s%s][%s]", r.host, fullPath, r.method)))
+		}
+	}
+	current.routes = append(current.routes, r)
+	sort.SliceStable(current.routes, func(i, j int) bool {
+		return current.routes[i].priority() > current.routes[j].priority()
+	})
+}
+
+// addParamChild 添加命名参数子节点, 相同约束的参数必须同名
+func (n *node) addParamChild(fullPath string, segment string) *node {
+	key, constraint, _ := parseParam(segment)
+	if key == "" {
+		panic(routeException(fmt.Sprintf("路由参数名不能为空 [%s]", fullPath)))
+	}
+	for _, child := range n.paramChildren {
+		if child.constraint != constraint {
+			continue
+		}
+		if child.key != key {
+			panic(routeException(fmt.Sprintf("路由参数冲突 [%s] 与已存在的 [%s]", fullPath, child.segment)))
+		}
+		return child
+	}
+	child := &node{
+		segment:    segment,
+		key:        key,
+		nType:      paramNode,
+		constraint: constraint,
+		check:      compileConstraint(fullPath, constraint),
+	}
+	n.paramChildren = append(n.paramChildren, child)
+	sort.SliceStable(n.paramChildren, func(i, j int) bool {
+		return n.paramChildren[i].check != nil && n.paramChildren[j].check == nil
+	})
+	return child
+}
+
+// getValue 查找与 path 匹配且满足域名和请求头条件的路由,匹配到的参数追加到 params
+// 匹配优先级: 静态节点 > 命名参数 > 通配参数,匹配失败时回溯
+func (n *node) getValue(path string, req *http.Request, params *Params) *route {
+	if path == "" {
+		if r := n.match(req, params); r != nil {
+			return r
+		}
+		return n.catchAll.matchCatchAll("/", req, params)
+	}
+
+	segment, rest := path[1:], ""
+	if i := strings.IndexByte(segment, '/'); i >= 0 {
+		segment, rest = segment[:i], segment[i:]
+	}
+
+	if child, ok := n.children[segment]; ok {
+		if r := child.getValue(rest, req, params); r != nil {
+			return r
+		}
+	}
+	if segment != "" {
+		for _, child := range n.paramChildren {
+			if child.check != nil && !child.check(segment) {
+				continue
+			}
+			*params = append(*params, Param{Key: child.key, Value: segment})
+			if r := child.getValue(rest, req, params); r != nil {
+				return r
+			}
+			*params = (*params)[:len(*params)-1]
+		}
+	}
+	return n.catchAll.matchCatchAll(path, req, params)
+}
+
+// findCaseInsensitivePath 忽略大小写查找路由, 返回静态部分按注册路由修正大小写后的路径
+func (n *node) findCaseInsensitivePath(path string, req *http.Request, params *Params) (string, bool) {
+	if path == "" {
+		if n.match(req, params) != nil || n.catchAll.matchCatchAll("/", req, params) != nil {
+			return "", true
+		}
+		return "", false
+	}
+
+	segment, rest := path[1:], ""
+	if i := strings.IndexByte(segment, '/'); i >= 0 {
+		segment, rest = segment[:i], segment[i:]
+	}
+
+	if child, ok := n.children[segment]; ok {
+		if fixedPath, found := child.findCaseInsensitivePath(rest, req, params); found {
+			return "/" + segment + fixedPath, true
+		}
+	}
+	for key, child := range n.children {
+		if key != segment && strings.EqualFold(key, segment) {
+			if fixedPath, found := child.findCaseInsensitivePath(rest, req, params); found {
+				return "/" + key + fixedPath, true
+			}
+		}
+	}
+	if segment != "" {
+		for _, child := range n.paramChildren {
+			if child.check != nil && !child.check(segment) {
+				continue
+			}
+			if fixedPath, found := child.findCaseInsensitivePath(rest, req, params); found {
+				return "/" + segment + fixedPath, true
+			}
+		}
+	}
+	if n.catchAll.matchCatchAll(path, req, params) != nil {
+		return path, true
+	}
+	return "", false
+}
+
+// matchCatchAll 通配节点匹配, value 为通配参数的值
+func (n *node) matchCatchAll(value string, req *http.Request, params *Params) *route {
+	if n == nil || len(n.routes) == 0 {
+		return nil
+	}
+	*params = append(*params, Param{Key: n.key, Value: value})
+	if r := n.match(req, params); r != nil {
+		return r
+	}
+	*params = (*params)[:len(*params)-1]
+	return nil
+}
+
+// match 在节点的路由中查找满足条件的路由
+func (n *node) match(req *http.Request, params *Params) *route {
+	for _, r := range n.routes {
+		if r.match(req, params) {
+			return r
+		}
+	}
+	return nil
+}
+
+// match 判断请求是否满足路由的请求头和域名条件, 域名中的参数追加到 params
+func (r *route) match(req *http.Request, params *Params) bool {
+	for _, h := range r.headers {
+		values, ok := req.Header[h.key]
+		if !ok || (h.value != "" && !util.StringInSlice(h.value, values)) {
+			return false
+		}
+	}
+	if r.host == "" {
+		return true
+	}
+	return matchHost(r.host, requestHost(req), params)
+}
+
+// priority 路由匹配优先级, 精确域名 > 通配域名 > 无域名, 请求头条件越多越优先
+func (r *route) priority() int {
+	priority := len(r.headers)
+	if r.host != "" {
+		priority += 100
+		if !strings.ContainsAny(r.host, ":*") {
+			priority += 100
+		}
+	}
+	return priority
+}
+
+// conditionKey 路由匹配条件的唯一标识, 用于判断路由是否重复
+func (r *route) conditionKey() string {
+	headers := make([]string, 0, len(r.headers))
+	for _, h := range r.headers {
+		headers = append(headers, h.key+"="+h.value)
+	}
+	sort.Strings(headers)
+	return r.host + "|" + strings.Join(headers, "&")
+}
+
+// matchHost 按 . 分段匹配域名, :name 捕获该段为参数, * 匹配任意一段
+func matchHost(pattern string, host string, params *Params) bool {
+	n := len(*params)
+	for {
+		patternLabel, patternRest, patternMore := strings.Cut(pattern, ".")
+		hostLabel, hostRest, hostMore := strings.Cut(host, ".")
+		switch {
+		case strings.HasPrefix(patternLabel, ":") && hostLabel != "":
+			*params = append(*params, Param{Key: patternLabel[1:], Value: hostLabel})
+		case patternLabel == "*" && hostLabel != "":
+		case strings.EqualFold(patternLabel, hostLabel):
+		default:
+			*params = (*params)[:n]
+			return false
+		}
+		if patternMore != hostMore {
+			*params = (*params)[:n]
+			return false
+		}
+		if !patternMore {
+			return true
+		}
+		pattern, host = patternRest, hostRest
+	}
+}
+
+// requestHost 获取请求的域名, 去掉端口
+func requestHost(req *http.Request) string {
+	host := req.Host
+	if i := strings.LastIndexByte(host, ':'); i > strings.LastIndexByte(host, ']') {
+		host = host[:i]
+	}
+	return host
+}
+
+// countParams 统计路由中的参数个数, 包含域名中的参数
+func countParams(fullPath string, host string) int {
+	return strings.Count(fullPath, "/:") + strings.Count(fullPath, "/*") + strings.Count(fullPath, "/{") +
+		strings.Count("."+host, ".:")
+}
+
+// isParam 判断路由段是否为命名参数
+func isParam(segment string) bool {
+	_, _, ok := parseParam(segment)
+	return ok
+}
+
+// routeException 路由注册异常
+func routeException(message string) Exception {
+	return Exception{
+		StateCode: http.StatusInternalServerError,
+		ErrorCode: ErrorCode.EXCEPTION,
+		Message:   message,
+	}
+}
@@ -0,0 +1,264 @@
+package thinko
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// routeCase 路由匹配用例, want 为处理函数输出的内容
+type routeCase struct {
+	name   string
+	method string
+	target string
+	host   string
+	header map[string]string
+	code   int
+	want   string
+}
+
+// echo 输出固定内容和路由参数, 用于判断匹配到的路由
+func echo(name string, keys ...string) HandlerFunc {
+	return func(ctx *Context) {
+		body := name
+		for _, key := range keys {
+			body += " " + key + "=" + ctx.Param(key)
+		}
+		ctx.Response.Write([]byte(body))
+	}
+}
+
+func runRouteCases(t *testing.T, engine *Engine, cases []routeCase) {
+	t.Helper()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			method := c.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, c.target, nil)
+			if c.host != "" {
+				r.Host = c.host
+			}
+			for key, value := range c.header {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			code := c.code
+			if code == 0 {
+				code = http.StatusOK
+			}
+			if w.Code != code {
+				t.Fatalf("%s %s 状态码为 %d, 期望 %d", method, c.target, w.Code, code)
+			}
+			if c.want != "" && w.Body.String() != c.want {
+				t.Fatalf("%s %s 输出 %q, 期望 %q", method, c.target, w.Body.String(), c.want)
+			}
+		})
+	}
+}
+
+func TestRoutePriority(t *testing.T) {
+	engine := New()
+	engine.GET("/user/list", echo("static"))
+	engine.GET("/user/:id", echo("param", "id"))
+	engine.GET("/user/*path", echo("catchAll", "path"))
+	engine.GET("/file/*path", echo("file", "path"))
+
+	runRouteCases(t, engine, []routeCase{
+		{name: "静态路由优先", target: "/user/list", want: "static"},
+		{name: "命名参数次之", target: "/user/1", want: "param id=1"},
+		{name: "通配参数最后", target: "/user/1/posts", want: "catchAll path=/1/posts"},
+		{name: "通配参数匹配多段", target: "/file/static/js/app.js", want: "file path=/static/js/app.js"},
+	})
+}
+
+func TestRouteBacktracking(t *testing.T) {
+	engine := New()
+	engine.GET("/user/new/edit", echo("newEdit"))
+	engine.GET("/user/:id/profile", echo("profile", "id"))
+	engine.GET("/user/:id/posts/:postId", echo("post", "id", "postId"))
+	engine.GET("/static/css/app.css", echo("css"))
+	engine.GET("/static/*path", echo("static", "path"))
+
+	runRouteCases(t, engine, []routeCase{
+		{name: "静态分支完整匹配", target: "/user/new/edit", want: "newEdit"},
+		{name: "静态分支失败后回溯到参数", target: "/user/new/profile", want: "profile id=new"},
+		{name: "多个参数", target: "/user/1/posts/2", want: "post id=1 postId=2"},
+		{name: "回溯时清理已匹配的参数", target: "/user/new/posts/3", want: "post id=new postId=3"},
+		{name: "静态分支失败后回溯到通配", target: "/static/css/app.js", want: "static path=/css/app.js"},
+		{name: "全部分支失败", target: "/user/1/unknown", code: http.StatusNotFound},
+	})
+}
+
+func TestRouteConstraints(t *testing.T) {
+	engine := New()
+	engine.GET("/user/{id:int}", echo("int", "id"))
+	engine.GET("/user/{uuid:uuid}", echo("uuid", "uuid"))
+	engine.GET("/user/{name}", echo("name", "name"))
+	engine.GET("/post/{slug:[a-z]+}/comments", echo("comments", "slug"))
+	engine.GET("/post/{id:uint}/likes", echo("likes", "id"))
+	engine.GET("/tag/{tag:alpha}", echo("tag", "tag"))
+
+	runRouteCases(t, engine, []routeCase{
+		{name: "整数约束", target: "/user/42", want: "int id=42"},
+		{name: "负整数", target: "/user/-1", want: "int id=-1"},
+		{name: "UUID 约束", target: "/user/123e4567-e89b-12d3-a456-426614174000", want: "uuid uuid=123e4567-e89b-12d3-a456-426614174000"},
+		{name: "约束不满足时使用无约束参数", target: "/user/tom", want: "name name=tom"},
+		{name: "正则约束", target: "/post/hello/comments", want: "comments slug=hello"},
+		{name: "正则约束完整匹配", target: "/post/Hello/comments", code: http.StatusNotFound},
+		{name: "约束不满足时回溯到后续分支", target: "/post/7/likes", want: "likes id=7"},
+		{name: "无符号整数约束", target: "/post/-7/likes", code: http.StatusNotFound},
+		{name: "字母约束", target: "/tag/go", want: "tag tag=go"},
+		{name: "字母约束不满足", target: "/tag/go1", code: http.StatusNotFound},
+	})
+}
+
+func TestRouteConditions(t *testing.T) {
+	engine := New()
+	engine.Host("admin.example.com").GET("/home", echo("adminHost"))
+	engine.Host(":tenant.example.com").GET("/home", echo("tenantHost", "tenant"))
+	engine.Host(":userName.Blog.Example.com").GET("/home", echo("blogHost", "userName"))
+	engine.Header("Accept-Version", "2").GET("/home", echo("header"))
+	engine.GET("/home", echo("default"))
+
+	runRouteCases(t, engine, []routeCase{
+		{name: "精确域名优先", target: "/home", host: "admin.example.com", want: "adminHost"},
+		{name: "通配域名次之", target: "/home", host: "shop.example.com", want: "tenantHost tenant=shop"},
+		{name: "域名忽略端口", target: "/home", host: "admin.example.com:8080", want: "adminHost"},
+		{name: "域名忽略大小写, 参数名保持原样", target: "/home", host: "Tom.BLOG.example.com", want: "blogHost userName=Tom"},
+		{name: "请求头条件", target: "/home", header: map[string]string{"Accept-Version": "2"}, want: "header"},
+		{name: "请求头不满足时使用无条件路由", target: "/home", header: map[string]string{"Accept-Version": "1"}, want: "default"},
+		{name: "无条件路由", target: "/home", want: "default"},
+	})
+
+	for _, host := range []string{"example.com:8080", ":tenant.example.com:80", "example..com", ":.example.com"} {
+		t.Run("错误的域名规则 "+host, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("域名规则 %s 应在注册时报错", host)
+				}
+			}()
+			engine.Host(host)
+		})
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	engine := New()
+	engine.GET("/user/:id", echo("get"))
+	engine.POST("/user/:id", echo("post"))
+	engine.DELETE("/user/:id", echo("delete"))
+
+	cases := []struct {
+		name   string
+		method string
+		code   int
+		allow  string
+	}{
+		{name: "未注册的请求方法返回 405", method: http.MethodPut, code: http.StatusMethodNotAllowed, allow: "DELETE, GET, HEAD, OPTIONS, POST"},
+		{name: "自动响应 OPTIONS", method: http.MethodOptions, code: http.StatusNoContent, allow: "DELETE, GET, HEAD, OPTIONS, POST"},
+		{name: "HEAD 使用 GET 路由", method: http.MethodHead, code: http.StatusOK},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(c.method, "/user/1", nil))
+			if w.Code != c.code {
+				t.Fatalf("状态码为 %d, 期望 %d", w.Code, c.code)
+			}
+			if allow := w.Header().Get("Allow"); allow != c.allow {
+				t.Fatalf("Allow 为 %q, 期望 %q", allow, c.allow)
+			}
+		})
+	}
+
+	engine.HandleMethodNotAllowed = false
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/user/1", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("关闭 HandleMethodNotAllowed 后状态码为 %d, 期望 404", w.Code)
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	engine := New()
+	engine.CaseInsensitive = true
+	engine.GET("/users", echo("users"))
+	engine.POST("/users", echo("create"))
+	engine.GET("/Docs/Guide", echo("guide"))
+
+	cases := []struct {
+		name     string
+		method   string
+		target   string
+		code     int
+		location string
+	}{
+		{name: "GET 去掉末尾斜杠使用 301", method: http.MethodGet, target: "/users/", code: http.StatusMovedPermanently, location: "/users"},
+		{name: "POST 去掉末尾斜杠使用 308", method: http.MethodPost, target: "/users/", code: http.StatusPermanentRedirect, location: "/users"},
+		{name: "清理多余的斜杠", method: http.MethodGet, target: "//users", code: http.StatusMovedPermanently, location: "/users"},
+		{name: "清理 ..", method: http.MethodGet, target: "/a/../users", code: http.StatusMovedPermanently, location: "/users"},
+		{name: "保留查询参数", method: http.MethodGet, target: "/users/?page=2", code: http.StatusMovedPermanently, location: "/users?page=2"},
+		{name: "忽略大小写", method: http.MethodGet, target: "/docs/guide", code: http.StatusMovedPermanently, location: "/Docs/Guide"},
+		{name: "规范路径直接匹配", method: http.MethodGet, target: "/users", code: http.StatusOK},
+		{name: "不存在的路由", method: http.MethodGet, target: "/posts/", code: http.StatusNotFound},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(c.method, c.target, nil))
+			if w.Code != c.code {
+				t.Fatalf("状态码为 %d, 期望 %d", w.Code, c.code)
+			}
+			if location := w.Header().Get("Location"); location != c.location {
+				t.Fatalf("Location 为 %q, 期望 %q", location, c.location)
+			}
+		})
+	}
+}
+
+func TestURL(t *testing.T) {
+	engine := New()
+	engine.GET("/user/:id", echo("user")).Name("user.show")
+	engine.GET("/user/:id/posts/:postId", echo("post")).Name("user.post")
+	engine.GET("/static/*path", echo("static")).Name("static")
+
+	cases := []struct {
+		name   string
+		route  string
+		params []any
+		want   string
+	}{
+		{name: "命名参数", route: "user.show", params: []any{"id", 1}, want: "/user/1"},
+		{name: "多余的参数作为查询参数", route: "user.show", params: []any{"id", 1, "tab", "posts"}, want: "/user/1?tab=posts"},
+		{name: "多个命名参数", route: "user.post", params: []any{"id", 1, "postId", 2}, want: "/user/1/posts/2"},
+		{name: "转义命名参数", route: "user.show", params: []any{"id", "a b/c"}, want: "/user/a%20b%2Fc"},
+		{name: "通配参数", route: "static", params: []any{"path", "/css/app.css"}, want: "/static/css/app.css"},
+		{name: "通配参数按段转义", route: "static", params: []any{"path", "docs/a b/c?d#e.md"}, want: "/static/docs/a%20b/c%3Fd%23e.md"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := engine.URL(c.route, c.params...); got != c.want {
+				t.Fatalf("URL 为 %q, 期望 %q", got, c.want)
+			}
+		})
+	}
+
+	panics := map[string]func(){
+		"路由名称不存在": func() { engine.URL("unknown") },
+		"参数不成对":   func() { engine.URL("user.show", "id") },
+		"缺少路由参数":  func() { engine.URL("user.show", "tab", "posts") },
+	}
+	for name, fn := range panics {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("期望 panic")
+				}
+			}()
+			fn()
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// UUIDv7 生成 UUID v7, 前 48 位为毫秒时间戳, 按生成时间有序
+func UUIDv7() string {
+	var b [16]byte
+	rand.Read(b[6:])
+	ms := uint64(time.Now().UnixMilli())
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+	b[6] = b[6]&0x0f | 0x70 // 版本 7
+	b[8] = b[8]&0x3f | 0x80 // RFC 9562 变体
+
+	var dst [36]byte
+	hex.Encode(dst[0:8], b[0:4])
+	dst[8] = '-'
+	hex.Encode(dst[9:13], b[4:6])
+	dst[13] = '-'
+	hex.Encode(dst[14:18], b[6:8])
+	dst[18] = '-'
+	hex.Encode(dst[19:23], b[8:10])
+	dst[23] = '-'
+	hex.Encode(dst[24:], b[10:])
+	return string(dst[:])
+}
+
+// snowflakeEpoch 雪花算法起始时间 2024-01-01 00:00:00 UTC, 单位毫秒
+const snowflakeEpoch = 1704067200000
+
+// Snowflake 雪花算法 ID 生成器, 由 41 位毫秒时间戳、10 位节点号和 12 位序列号组成
+type Snowflake struct {
+	mutex    sync.Mutex
+	node     int64
+	last     int64
+	sequence int64
+}
+
+// NewSnowflake 创建雪花算法 ID 生成器, node 为节点号, 取值 0 到 1023, 多个实例应使用不同的节点号
+func NewSnowflake(node int64) *Snowflake {
+	if node < 0 || node > 1023 {
+		panic(fmt.Sprintf("雪花算法节点号 %d 超出范围 0-1023", node))
+	}
+	return &Snowflake{node: node}
+}
+
+// Next 生成 ID, 同一毫秒内序列号用完时等待下一毫秒
+func (s *Snowflake) Next() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now().UnixMilli()
+	// 时钟回拨时沿用上次的时间戳, 保证 ID 递增
+	if now < s.last {
+		now = s.last
+	}
+	if now == s.last {
+		s.sequence = (s.sequence + 1) & 4095
+		if s.sequence == 0 {
+			for now <= s.last {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Now().UnixMilli()
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.last = now
+	return (now-snowflakeEpoch)<<22 | s.node<<12 | s.sequence
+}
+
+// NextString 生成字符串形式的 ID, 可作为请求 ID 生成函数
+func (s *Snowflake) NextString() string {
+	return strconv.FormatInt(s.Next(), 10)
+}